@@ -0,0 +1,251 @@
+package delaunay
+
+import (
+	"fmt"
+
+	"github.com/edwardbrowncross/naturalneighbour/geom"
+)
+
+// AddConstraint makes the segment between two points of the triangulation one of its edges, such as a cliff, river
+// or boundary that the triangulation should not join points across.
+// The edges crossing the segment are flipped out of its way, and the triangles around it flipped until they are
+// delaunay except where the segment hides points from them, making a constrained delaunay triangulation.
+// No edge crosses a constraint, so no point is connected to another across one, and constraints are never flipped
+// away as points are added, moved or removed.
+// Where the segment passes exactly through other points, it is split into a constraint between each of them, and
+// a point added on a constraint later splits it in two. Removing a point removes the constraints that end at it,
+// except that a point splitting a straight constraint leaves it whole. Constraints are all removed if the
+// triangulation loses its triangles.
+// Returns an error wrapping ErrConstraintCrossing, without adding any of the segment, if it crosses another
+// constraint. Undo functions for points added before the constraint will no longer work.
+// Chew, "Constrained Delaunay triangulations" (1989); Sloan, "A fast algorithm for generating constrained Delaunay
+// triangulations" (1993).
+func (t *Triangulation) AddConstraint(p1, p2 *Point) error {
+	for _, p := range []*Point{p1, p2} {
+		if p == nil {
+			return fmt.Errorf("%w: nil point", ErrDegenerate)
+		}
+		if p.infinite {
+			return fmt.Errorf("%w: cannot constrain it", ErrBoundingPoint)
+		}
+		if !t.HasPoint(p) {
+			return fmt.Errorf("%w: (%f,%f)", ErrPointNotFound, p.X, p.Y)
+		}
+	}
+	if p1 == p2 {
+		return fmt.Errorf("%w: constraint from (%f,%f) to itself", ErrDegenerate, p1.X, p1.Y)
+	}
+	if t.isFlat() {
+		return fmt.Errorf("%w: triangulation has no three points that are not collinear", ErrDegenerate)
+	}
+	// Check the whole segment before changing anything, so that a crossing leaves the triangulation as it was.
+	for a := p1; a != p2; {
+		end, _, err := getCrossedEdges(a, p2)
+		if err != nil {
+			return err
+		}
+		a = end
+	}
+	for a := p1; a != p2; {
+		end, crossed, err := getCrossedEdges(a, p2)
+		if err != nil {
+			return err
+		}
+		if err := recoverEdge(a, end, crossed); err != nil {
+			return err
+		}
+		a = end
+	}
+	return nil
+}
+
+// IsConstrainedTo returns whether the edge between p and q is a constraint of the triangulation.
+func (p *Point) IsConstrainedTo(q *Point) bool {
+	for _, c := range p.constraints {
+		if c == q {
+			return true
+		}
+	}
+	return false
+}
+
+// GetConstrained returns the points that this point is joined to by constraints.
+func (p *Point) GetConstrained() []*Point {
+	return append([]*Point(nil), p.constraints...)
+}
+
+// getCrossedEdges returns the edges crossed by the segment from a towards b, in order from a, as far as the first
+// point of the triangulation that the segment reaches, which is also returned: b, or a point lying on the segment.
+// Returns an error wrapping ErrConstraintCrossing if one of the edges is a constraint.
+func getCrossedEdges(a, b *Point) (*Point, [][2]*Point, error) {
+	// Find the triangle around a that the segment leaves a through.
+	var s *Triangle
+	var u, w *Point
+	for _, tri := range a.Triangles {
+		k := tri.indexOf(a)
+		u, w = tri.Points[(k+1)%3], tri.Points[(k+2)%3]
+		for _, v := range []*Point{u, w} {
+			if v == b || isOnSegment(a, b, v) {
+				return v, nil, nil
+			}
+		}
+		if u.infinite || w.infinite {
+			continue
+		}
+		// The triangle is clockwise, so the segment passes through it if it is clockwise of au and anti-clockwise of aw.
+		if geom.Orient2d(a.X, a.Y, u.X, u.Y, b.X, b.Y) < 0 && geom.Orient2d(a.X, a.Y, w.X, w.Y, b.X, b.Y) > 0 {
+			s = tri
+			break
+		}
+	}
+	if s == nil {
+		return nil, nil, fmt.Errorf("%w: no triangle around (%f,%f) towards (%f,%f)", ErrCorrupted, a.X, a.Y, b.X, b.Y)
+	}
+	// Walk across the edges the segment crosses. u is always to its left and w to its right.
+	crossed := [][2]*Point{}
+	for {
+		if u.IsConstrainedTo(w) {
+			return nil, nil, fmt.Errorf("%w: (%f,%f)-(%f,%f) crosses (%f,%f)-(%f,%f)", ErrConstraintCrossing,
+				a.X, a.Y, b.X, b.Y, u.X, u.Y, w.X, w.Y)
+		}
+		crossed = append(crossed, [2]*Point{u, w})
+		next := s.GetAdjacentTo(u, w)
+		if next == nil || next.IsGhost() {
+			return nil, nil, fmt.Errorf("%w: segment (%f,%f)-(%f,%f) leaves the hull", ErrCorrupted, a.X, a.Y, b.X, b.Y)
+		}
+		v := next.GetPointOpposite(s)
+		if v == b || isOnSegment(a, b, v) {
+			return v, crossed, nil
+		}
+		if geom.Orient2d(a.X, a.Y, b.X, b.Y, v.X, v.Y) > 0 {
+			u = v
+		} else {
+			w = v
+		}
+		s = next
+	}
+}
+
+// recoverEdge makes the segment from a to b an edge of the triangulation and a constraint, given the edges that
+// cross it. Each crossing edge that is the diagonal of a convex quadrilateral is flipped, and the others are tried
+// again once their neighbours have been. The new edges are then flipped until they are constrained delaunay.
+func recoverEdge(a, b *Point, crossed [][2]*Point) error {
+	created := [][2]*Point{}
+	for len(crossed) != 0 {
+		e := crossed[0]
+		crossed = crossed[1:]
+		t1 := getTriangleWithEdge(e[0], e[1])
+		if t1 == nil {
+			return fmt.Errorf("%w: edge (%f,%f)-(%f,%f) not found", ErrCorrupted, e[0].X, e[0].Y, e[1].X, e[1].Y)
+		}
+		t2 := t1.GetAdjacentTo(e[0], e[1])
+		if !isConvexWith(t1, t2) {
+			crossed = append(crossed, e)
+			continue
+		}
+		c, d := t1.GetPointOpposite(t2), t2.GetPointOpposite(t1)
+		if err := t1.FlipWith(t2); err != nil {
+			return fmt.Errorf("could not flip triangles: %w", err)
+		}
+		if isCrossing(a, b, c, d) {
+			crossed = append(crossed, [2]*Point{c, d})
+		} else {
+			created = append(created, [2]*Point{c, d})
+		}
+	}
+	constrain(a, b)
+	triangles := []*Triangle{}
+	for _, e := range created {
+		if t1 := getTriangleWithEdge(e[0], e[1]); t1 != nil {
+			triangles = append(triangles, t1)
+		}
+	}
+	return restoreDelaunay(triangles)
+}
+
+// getTriangleWithEdge returns a leaf triangle with the edge from a to b, or nil if there is no such edge.
+func getTriangleWithEdge(a, b *Point) *Triangle {
+	for _, s := range a.Triangles {
+		if s.indexOf(b) >= 0 {
+			return s
+		}
+	}
+	return nil
+}
+
+// isOnSegment returns whether v lies on the segment from a towards b, and is not a. Points of a triangulation
+// beyond b cannot be on an edge out of a, so are not checked for.
+func isOnSegment(a, b, v *Point) bool {
+	if v.infinite || v == a || geom.Orient2d(a.X, a.Y, b.X, b.Y, v.X, v.Y) != 0 {
+		return false
+	}
+	return (v.X-a.X)*(b.X-a.X)+(v.Y-a.Y)*(b.Y-a.Y) > 0
+}
+
+// isCrossing returns whether the segments from a to b and from c to d cross at a point inside both of them.
+func isCrossing(a, b, c, d *Point) bool {
+	o1 := geom.Orient2d(a.X, a.Y, b.X, b.Y, c.X, c.Y)
+	o2 := geom.Orient2d(a.X, a.Y, b.X, b.Y, d.X, d.Y)
+	o3 := geom.Orient2d(c.X, c.Y, d.X, d.Y, a.X, a.Y)
+	o4 := geom.Orient2d(c.X, c.Y, d.X, d.Y, b.X, b.Y)
+	return (o1 < 0 && o2 > 0 || o1 > 0 && o2 < 0) && (o3 < 0 && o4 > 0 || o3 > 0 && o4 < 0)
+}
+
+// constrain makes the edge between a and b a constraint.
+func constrain(a, b *Point) {
+	if !a.IsConstrainedTo(b) {
+		a.constraints = append(a.constraints, b)
+		b.constraints = append(b.constraints, a)
+	}
+}
+
+// unconstrain stops the edge between a and b being a constraint.
+func unconstrain(a, b *Point) {
+	a.constraints = removeConstrained(a.constraints, b)
+	b.constraints = removeConstrained(b.constraints, a)
+}
+
+// removeConstrained returns the list of constrained points without p.
+func removeConstrained(constraints []*Point, p *Point) []*Point {
+	for i, q := range constraints {
+		if q == p {
+			last := len(constraints) - 1
+			constraints[i] = constraints[last]
+			constraints[last] = nil
+			return constraints[:last]
+		}
+	}
+	return constraints
+}
+
+// unconstrainAll removes every constraint that ends at p, returning the points they joined it to.
+func unconstrainAll(p *Point) []*Point {
+	constrained := p.constraints
+	for _, q := range constrained {
+		q.constraints = removeConstrained(q.constraints, p)
+	}
+	p.constraints = nil
+	return constrained
+}
+
+// constraintSplitter splits a constraint at a point added on it, and joins it back together when undone.
+type constraintSplitter struct {
+	a, b, p *Point
+}
+
+func newConstraintSplitter(a, b, p *Point) constraintSplitter {
+	unconstrain(a, b)
+	constrain(a, p)
+	constrain(p, b)
+	return constraintSplitter{
+		a: a,
+		b: b,
+		p: p,
+	}
+}
+func (u constraintSplitter) Undo() error {
+	unconstrain(u.a, u.p)
+	unconstrain(u.p, u.b)
+	constrain(u.a, u.b)
+	return nil
+}
@@ -0,0 +1,421 @@
+package delaunay
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/edwardbrowncross/naturalneighbour/geom"
+)
+
+// quadEdges is a quad-edge structure over the points with the given interleaved x and y coordinates, stored in flat
+// arrays. Each edge has four directed parts, numbered 4q to 4q+3: the edge, its dual rotated anti-clockwise, the
+// edge reversed and the dual reversed. Only the primal parts, 4q and 4q+2, have points at their ends.
+// Guibas & Stolfi, "Primitives for the manipulation of general subdivisions and the computation of Voronoi
+// diagrams" (1985).
+type quadEdges struct {
+	coords []float64
+	next   []int32 // Next directed edge anti-clockwise around the origin of each directed edge.
+	origin []int32 // Origin of each directed primal edge e, at origin[e/2]. Negative for deleted edges.
+}
+
+// edgeArena is the set of quad edges that one part of a divide and conquer triangulation can make new edges from.
+// Parts that are triangulated at the same time have separate arenas, so they never write to the same edges.
+type edgeArena struct {
+	free   []int32    // Deleted quad edges, for reuse.
+	unused [][2]int32 // Ranges of quad edges that have never been used.
+}
+
+// divideAndConquerGrain is the number of points below which a part of a divide and conquer triangulation is not
+// split between goroutines.
+const divideAndConquerGrain = 1 << 13
+
+// buildDivideAndConquer adds the given points to an empty triangulation all at once, using divideAndConquer with
+// the given number of goroutines.
+func (t *Triangulation) buildDivideAndConquer(points []*Point, workers int) error {
+	coords := make([]float64, 0, 2*len(points))
+	for _, p := range points {
+		if err := checkPoint(p); err != nil {
+			return err
+		}
+		coords = append(coords, p.X, p.Y)
+	}
+	t.location = LocateWalk
+	m, err := divideAndConquer(coords, workers)
+	switch {
+	case errors.Is(err, ErrDuplicatePoint):
+		return firstDuplicate(points)
+	case errors.Is(err, ErrDegenerate):
+		// Without three points that are not collinear there are no triangles, so there is nothing to build.
+		return t.addPoints(points)
+	case err != nil:
+		return err
+	}
+	t.fromMesh(points, m)
+	t.points = append([]*Point(nil), points...)
+	return nil
+}
+
+// fromMesh makes the triangles of the triangulation from those of the given mesh of the given points.
+// The triangles, and each point's list of them, are carved out of a few large allocations.
+func (t *Triangulation) fromMesh(points []*Point, m *Mesh) {
+	triangles := make([]Triangle, m.NumTriangles())
+	degree := make([]int, len(points)+1)
+	for _, v := range m.Triangles {
+		if v == InfiniteIndex {
+			v = int32(len(points))
+		}
+		degree[v]++
+	}
+	// Each list is capped, so that adding a triangle to one later does not overwrite the next.
+	lists := make([]*Triangle, len(m.Triangles))
+	for i, p := range points {
+		p.Triangles, lists = lists[:0:degree[i]], lists[degree[i]:]
+	}
+	t.Infinite.Triangles = lists[:0]
+	for k := range triangles {
+		tr := &triangles[k]
+		tr.Children = []*Triangle{}
+		for j, v := range m.Triangles[3*k : 3*k+3] {
+			p := t.Infinite
+			if v != InfiniteIndex {
+				p = points[v]
+			}
+			tr.Points[j] = p
+			p.addTriangle(tr)
+		}
+	}
+	t.last = &triangles[0]
+}
+
+// divideAndConquer builds a delaunay triangulation of the points with the given interleaved x and y coordinates by
+// splitting them in two by x coordinate, triangulating each half and merging the halves back together, which takes
+// O(n log n) time whatever the points. The coordinates must be finite.
+// The two halves of each large part are triangulated at the same time, using up to the given number of goroutines.
+// Each part makes its edges from its own share of the quad edges, whether or not it has a goroutine to itself, so
+// the result does not depend on the number of goroutines.
+// Returns an error wrapping ErrDuplicatePoint if two points have the same coordinates, or ErrDegenerate if there
+// are not three points that are not collinear.
+// Guibas & Stolfi (1985), section 8.
+func divideAndConquer(coords []float64, workers int) (*Mesh, error) {
+	n := len(coords) / 2
+	if n < 3 {
+		return nil, fmt.Errorf("%w: %d points cannot be triangulated", ErrDegenerate, n)
+	}
+	sorted := make([]int32, n)
+	for i := range sorted {
+		sorted[i] = int32(i)
+	}
+	sem := make(chan struct{}, workers-1)
+	sortPoints(sorted, make([]int32, n), coords, sem)
+	for j := 1; j < n; j++ {
+		a, b := sorted[j-1], sorted[j]
+		if coords[2*a] == coords[2*b] && coords[2*a+1] == coords[2*b+1] {
+			return nil, fmt.Errorf("%w: points %d and %d", ErrDuplicatePoint, a, b)
+		}
+	}
+	// A planar graph of k points has fewer than 3k edges, so each part of the triangulation can have 3k quad edges
+	// to itself.
+	q := &quadEdges{
+		coords: coords,
+		next:   make([]int32, 12*n),
+		origin: make([]int32, 6*n),
+	}
+	for k := range q.origin {
+		q.origin[k] = -1
+	}
+	var ar edgeArena
+	le, _ := q.buildParallel(sorted, 0, &ar, sem)
+	return q.toMesh(qSym(le))
+}
+
+// buildParallel is like build, but triangulates the halves of large parts with separate goroutines while there are
+// tokens to take from sem. The part has the quad edges from first to first+3*len(s), which are added to the arena.
+func (q *quadEdges) buildParallel(s []int32, first int32, ar *edgeArena, sem chan struct{}) (le, re int32) {
+	if len(s) < 2*divideAndConquerGrain {
+		ar.unused = append(ar.unused, [2]int32{first, first + 3*int32(len(s))})
+		return q.build(s, ar)
+	}
+	half := len(s) / 2
+	var ldo, ldi int32
+	var left edgeArena
+	done := fork(sem, func() { ldo, ldi = q.buildParallel(s[:half], first, &left, sem) })
+	var right edgeArena
+	rdi, rdo := q.buildParallel(s[half:], first+3*int32(half), &right, sem)
+	<-done
+	ar.free = append(append(ar.free, left.free...), right.free...)
+	ar.unused = append(append(ar.unused, left.unused...), right.unused...)
+	return q.merge(ldo, ldi, rdi, rdo, ar)
+}
+
+// sortPoints sorts the given point indices by x and then y coordinate, sorting the halves of long runs with
+// separate goroutines while there are tokens to take from sem, and merging them through buf, which must be as long.
+func sortPoints(s, buf []int32, coords []float64, sem chan struct{}) {
+	less := func(i, j int32) bool {
+		if coords[2*i] != coords[2*j] {
+			return coords[2*i] < coords[2*j]
+		}
+		return coords[2*i+1] < coords[2*j+1]
+	}
+	if len(s) < 2*divideAndConquerGrain {
+		sort.Slice(s, func(a, b int) bool { return less(s[a], s[b]) })
+		return
+	}
+	half := len(s) / 2
+	done := fork(sem, func() { sortPoints(s[:half], buf[:half], coords, sem) })
+	sortPoints(s[half:], buf[half:], coords, sem)
+	<-done
+	copy(buf, s)
+	i, j := 0, half
+	for k := range s {
+		if j == len(s) || (i < half && !less(buf[j], buf[i])) {
+			s[k] = buf[i]
+			i++
+		} else {
+			s[k] = buf[j]
+			j++
+		}
+	}
+}
+
+// fork calls f with a new goroutine if there is a token to take from sem, giving the token back once f has
+// finished, or otherwise calls it straight away. The channel returned is closed once f has finished.
+func fork(sem chan struct{}, f func()) <-chan struct{} {
+	done := make(chan struct{})
+	select {
+	case sem <- struct{}{}:
+		go func() {
+			f()
+			<-sem
+			close(done)
+		}()
+	default:
+		f()
+		close(done)
+	}
+	return done
+}
+
+// build triangulates the points with the given indices, which are sorted by x and then y coordinate, making edges
+// from the given arena. Returns the anti-clockwise convex hull edge out of the leftmost point and the clockwise
+// convex hull edge out of the rightmost point.
+func (q *quadEdges) build(s []int32, ar *edgeArena) (le, re int32) {
+	switch len(s) {
+	case 2:
+		a := q.makeEdge(s[0], s[1], ar)
+		return a, qSym(a)
+	case 3:
+		a := q.makeEdge(s[0], s[1], ar)
+		b := q.makeEdge(s[1], s[2], ar)
+		q.splice(qSym(a), b)
+		if q.ccw(s[0], s[1], s[2]) {
+			q.connect(b, a, ar)
+			return a, qSym(b)
+		}
+		if q.ccw(s[0], s[2], s[1]) {
+			c := q.connect(b, a, ar)
+			return qSym(c), c
+		}
+		// The points are collinear, so there is no triangle.
+		return a, qSym(b)
+	}
+	ldo, ldi := q.build(s[:len(s)/2], ar)
+	rdi, rdo := q.build(s[len(s)/2:], ar)
+	return q.merge(ldo, ldi, rdi, rdo, ar)
+}
+
+// merge joins the triangulations of two sets of points, the first entirely to the left of the second, given the
+// anti-clockwise and clockwise convex hull edges out of the leftmost and rightmost points of each. Returns those
+// edges of the joined triangulation.
+func (q *quadEdges) merge(ldo, ldi, rdi, rdo int32, ar *edgeArena) (le, re int32) {
+	// Find the lower common tangent of the two halves.
+	for {
+		if q.leftOf(q.org(rdi), ldi) {
+			ldi = q.lnext(ldi)
+		} else if q.rightOf(q.org(ldi), rdi) {
+			rdi = q.rprev(rdi)
+		} else {
+			break
+		}
+	}
+	basel := q.connect(qSym(rdi), ldi, ar)
+	if q.org(ldi) == q.org(ldo) {
+		ldo = qSym(basel)
+	}
+	if q.org(rdi) == q.org(rdo) {
+		rdo = basel
+	}
+	// Zip the halves together from the bottom up, joining each new cross edge to whichever candidate point above it
+	// has the empty circumcircle, and deleting the edges of each half that the new triangles make non-delaunay.
+	for {
+		lcand := q.next[qSym(basel)]
+		if q.valid(lcand, basel) {
+			for q.inCircle(q.dest(basel), q.org(basel), q.dest(lcand), q.dest(q.next[lcand])) {
+				t := q.next[lcand]
+				q.deleteEdge(lcand, ar)
+				lcand = t
+			}
+		}
+		rcand := q.oprev(basel)
+		if q.valid(rcand, basel) {
+			for q.inCircle(q.dest(basel), q.org(basel), q.dest(rcand), q.dest(q.oprev(rcand))) {
+				t := q.oprev(rcand)
+				q.deleteEdge(rcand, ar)
+				rcand = t
+			}
+		}
+		lvalid, rvalid := q.valid(lcand, basel), q.valid(rcand, basel)
+		if !lvalid && !rvalid {
+			break
+		}
+		if !lvalid || (rvalid && q.inCircle(q.dest(lcand), q.org(lcand), q.org(rcand), q.dest(rcand))) {
+			basel = q.connect(rcand, qSym(basel), ar)
+		} else {
+			basel = q.connect(qSym(basel), qSym(lcand), ar)
+		}
+	}
+	return ldo, rdo
+}
+
+// toMesh converts the finished triangulation into a Mesh, given an edge with the outside of the convex hull on
+// its left.
+func (q *quadEdges) toMesh(outer int32) (*Mesh, error) {
+	// edge[e/2] is the mesh edge running the same way as the directed primal edge e, which is in the triangle on
+	// the left of its reverse.
+	edge := make([]int32, len(q.origin))
+	for k := range edge {
+		edge[k] = -1
+	}
+	// Mark the edges around the outside first, so that it is not taken for a triangle.
+	hull := []int32{}
+	for e := outer; len(hull) == 0 || e != outer; e = q.lnext(e) {
+		hull = append(hull, e)
+		edge[qSym(e)/2] = -2
+	}
+	n := len(q.coords) / 2
+	m := &Mesh{
+		Coords:     q.coords,
+		Triangles:  make([]int32, 0, 3*(2*n-2)),
+		Neighbours: make([]int32, 3*(2*n-2)),
+		Incident:   make([]int32, n),
+	}
+	// Every other face is a triangle, with its points anti-clockwise around it.
+	for e := int32(0); int(e) < len(q.next); e += 2 {
+		if q.org(e) < 0 || edge[qSym(e)/2] != -1 {
+			continue
+		}
+		f := [3]int32{e, q.lnext(e), q.lnext(q.lnext(e))}
+		if q.lnext(f[2]) != e {
+			return nil, fmt.Errorf("%w: face of divide and conquer triangulation is not a triangle", ErrCorrupted)
+		}
+		// Reverse the triangle, so that it is clockwise.
+		t := int32(len(m.Triangles))
+		m.Triangles = append(m.Triangles, q.org(f[0]), q.org(f[2]), q.org(f[1]))
+		edge[qSym(f[2])/2], edge[qSym(f[1])/2], edge[qSym(f[0])/2] = t, t+1, t+2
+	}
+	if len(m.Triangles) == 0 {
+		return nil, fmt.Errorf("%w: all points are collinear", ErrDegenerate)
+	}
+	if expected := 2*n - 2 - len(hull); len(m.Triangles)/3 != expected {
+		return nil, fmt.Errorf("%w: divide and conquer triangulation has %d triangles but expected %d", ErrCorrupted, len(m.Triangles)/3, expected)
+	}
+	// Join each edge of the hull to the point at infinity, with the ghost triangle's edge running the same way as
+	// the hull edge's reverse, as in NewMesh.
+	m.ghost = int32(len(m.Triangles) / 3)
+	for j, h := range hull {
+		g := int32(len(m.Triangles))
+		m.Triangles = append(m.Triangles, q.dest(h), q.org(h), InfiniteIndex)
+		edge[qSym(h)/2] = g
+		// The previous ghost triangle shares the edge from the point at infinity to the origin of h.
+		p := 3*m.ghost + int32(3*((j+len(hull)-1)%len(hull)))
+		m.Neighbours[g+1], m.Neighbours[p+2] = p+2, g+1
+	}
+	for e := int32(0); int(e) < len(q.next); e += 2 {
+		if q.org(e) < 0 {
+			continue
+		}
+		m.Neighbours[edge[e/2]] = edge[qSym(e)/2]
+		m.Incident[q.org(e)] = edge[e/2]
+	}
+	return m, nil
+}
+
+// makeEdge adds a new edge from point a to point b, joined to no other edges, using a quad edge from the arena.
+func (q *quadEdges) makeEdge(a, b int32, ar *edgeArena) int32 {
+	var e int32
+	if len(ar.free) > 0 {
+		e = 4 * ar.free[len(ar.free)-1]
+		ar.free = ar.free[:len(ar.free)-1]
+	} else {
+		for ar.unused[0][0] == ar.unused[0][1] {
+			ar.unused = ar.unused[1:]
+		}
+		e = 4 * ar.unused[0][0]
+		ar.unused[0][0]++
+	}
+	q.next[e], q.next[e+1], q.next[e+2], q.next[e+3] = e, e+3, e+2, e+1
+	q.origin[e/2], q.origin[e/2+1] = a, b
+	return e
+}
+
+// splice joins or separates the rings of edges around the origins of a and b, and the rings around their left
+// faces.
+func (q *quadEdges) splice(a, b int32) {
+	alpha, beta := qRot(q.next[a]), qRot(q.next[b])
+	q.next[a], q.next[b] = q.next[b], q.next[a]
+	q.next[alpha], q.next[beta] = q.next[beta], q.next[alpha]
+}
+
+// connect adds a new edge from the destination of a to the origin of b, so that all three share a left face.
+func (q *quadEdges) connect(a, b int32, ar *edgeArena) int32 {
+	e := q.makeEdge(q.dest(a), q.org(b), ar)
+	q.splice(e, q.lnext(a))
+	q.splice(qSym(e), b)
+	return e
+}
+
+// deleteEdge removes the edge e from the structure, returning its quad edge to the arena.
+func (q *quadEdges) deleteEdge(e int32, ar *edgeArena) {
+	q.splice(e, q.oprev(e))
+	q.splice(qSym(e), q.oprev(qSym(e)))
+	q.origin[e&^3/2], q.origin[e&^3/2+1] = -1, -1
+	ar.free = append(ar.free, e/4)
+}
+
+func (q *quadEdges) org(e int32) int32  { return q.origin[e/2] }
+func (q *quadEdges) dest(e int32) int32 { return q.origin[qSym(e)/2] }
+
+// lnext returns the next edge anti-clockwise around the left face of e.
+func (q *quadEdges) lnext(e int32) int32 { return qRot(q.next[qInvRot(e)]) }
+
+// oprev returns the next edge clockwise around the origin of e.
+func (q *quadEdges) oprev(e int32) int32 { return qRot(q.next[qRot(e)]) }
+
+// rprev returns the next edge clockwise around the right face of e.
+func (q *quadEdges) rprev(e int32) int32 { return q.next[qSym(e)] }
+
+func qRot(e int32) int32    { return e&^3 | (e+1)&3 }
+func qInvRot(e int32) int32 { return e&^3 | (e+3)&3 }
+func qSym(e int32) int32    { return e ^ 2 }
+
+// ccw returns whether the points a, b and c are in anti-clockwise order.
+func (q *quadEdges) ccw(a, b, c int32) bool {
+	return geom.Orient2d(q.coords[2*a], q.coords[2*a+1], q.coords[2*b], q.coords[2*b+1], q.coords[2*c], q.coords[2*c+1]) > 0
+}
+
+// inCircle returns whether the point d is strictly inside the circumcircle of the anti-clockwise points a, b and c.
+func (q *quadEdges) inCircle(a, b, c, d int32) bool {
+	// Merging often tests a point of the circle itself, which is on it, but the exact test would take the slow path
+	// to find that.
+	if d == a || d == b || d == c {
+		return false
+	}
+	x, y := q.coords, q.coords[1:]
+	return geom.Incircle(x[2*a], y[2*a], x[2*b], y[2*b], x[2*c], y[2*c], x[2*d], y[2*d]) > 0
+}
+
+func (q *quadEdges) leftOf(p, e int32) bool  { return q.ccw(p, q.org(e), q.dest(e)) }
+func (q *quadEdges) rightOf(p, e int32) bool { return q.ccw(p, q.dest(e), q.org(e)) }
+
+// valid returns whether the destination of the candidate edge e is above the base edge of a merge.
+func (q *quadEdges) valid(e, basel int32) bool { return q.rightOf(q.dest(e), basel) }
@@ -0,0 +1,114 @@
+package delaunay
+
+import "fmt"
+
+// DuplicatePolicy selects what happens when several points given to a triangulation have the same coordinates.
+type DuplicatePolicy int
+
+const (
+	// DuplicateError returns a *DuplicatePointError.
+	DuplicateError DuplicatePolicy = iota
+	// DuplicateKeepFirst keeps the first of the points with the same coordinates and leaves the others out.
+	DuplicateKeepFirst
+	// DuplicateKeepLast keeps the last of the points with the same coordinates and leaves the others out.
+	DuplicateKeepLast
+	// DuplicateAverage keeps the first of the points with the same coordinates, setting each of its values to the
+	// mean of that value over all of them, and leaves the others out.
+	// The values are set on the caller's own first point, in place, since that is the point in the triangulation.
+	// Copy the points first to keep their original values.
+	DuplicateAverage
+)
+
+// DuplicatePointError is returned when a point cannot be added to a triangulation because another point there
+// already has the same coordinates.
+type DuplicatePointError struct {
+	Point    *Point // The point that was rejected.
+	Existing *Point // The point already at the same coordinates.
+}
+
+func (e *DuplicatePointError) Error() string {
+	return fmt.Sprintf("point (%f,%f) is already in the triangulation", e.Point.X, e.Point.Y)
+}
+
+// Unwrap returns ErrDuplicatePoint, so that errors.Is matches it.
+func (e *DuplicatePointError) Unwrap() error {
+	return ErrDuplicatePoint
+}
+
+// dedupe applies the duplicate policy to the given points, returning those that should be added to the
+// triangulation, in the order given. Points that are left out are not modified, but for DuplicateAverage the point
+// kept from each group has its values overwritten.
+func dedupe(points []*Point, policy DuplicatePolicy) ([]*Point, error) {
+	type coords struct{ x, y float64 }
+	groups := make(map[coords][]*Point, len(points))
+	for _, p := range points {
+		c := coords{p.X, p.Y}
+		groups[c] = append(groups[c], p)
+	}
+	if len(groups) == len(points) {
+		return points, nil
+	}
+	kept := make([]*Point, 0, len(groups))
+	for _, p := range points {
+		group := groups[coords{p.X, p.Y}]
+		if len(group) == 1 {
+			kept = append(kept, p)
+			continue
+		}
+		switch policy {
+		case DuplicateKeepFirst:
+			if p == group[0] {
+				kept = append(kept, p)
+			}
+		case DuplicateKeepLast:
+			if p == group[len(group)-1] {
+				kept = append(kept, p)
+			}
+		case DuplicateAverage:
+			if p != group[0] {
+				continue
+			}
+			if err := averageValues(group); err != nil {
+				return nil, err
+			}
+			kept = append(kept, p)
+		default:
+			return nil, &DuplicatePointError{Point: group[1], Existing: group[0]}
+		}
+	}
+	return kept, nil
+}
+
+// averageValues sets each value of the first of the given points to the mean of that value over all of them.
+func averageValues(group []*Point) error {
+	p := group[0]
+	n := p.GetNumValues()
+	for _, q := range group[1:] {
+		if q.GetNumValues() != n {
+			return fmt.Errorf("%w: points at (%f,%f)", ErrValueCount, p.X, p.Y)
+		}
+	}
+	for c := 0; c < n; c++ {
+		total := 0.0
+		for _, q := range group {
+			total += q.GetValue(c)
+		}
+		p.SetValue(c, total/float64(len(group)))
+	}
+	return nil
+}
+
+// firstDuplicate returns a *DuplicatePointError for the first of the given points that has the same coordinates as
+// one before it, or nil if there is none.
+func firstDuplicate(points []*Point) error {
+	type coords struct{ x, y float64 }
+	seen := make(map[coords]*Point, len(points))
+	for _, p := range points {
+		c := coords{p.X, p.Y}
+		if q, found := seen[c]; found {
+			return &DuplicatePointError{Point: p, Existing: q}
+		}
+		seen[c] = p
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package delaunay
+
+import "errors"
+
+// Errors returned by the triangulation. They are wrapped with details of the failure, so should be checked for
+// with errors.Is.
+var (
+	// ErrDuplicatePoint is returned when a point has the same coordinates as one already in the triangulation.
+	// From a Triangulation, the error is a *DuplicatePointError, which gives the points involved.
+	ErrDuplicatePoint = errors.New("point is already in the triangulation")
+	// ErrPointNotFound is returned for a point that is not in the triangulation.
+	ErrPointNotFound = errors.New("point is not in the triangulation")
+	// ErrBoundingPoint is returned when trying to remove or move the point at infinity.
+	ErrBoundingPoint = errors.New("point is the point at infinity")
+	// ErrConstraintCrossing is returned when a constraint would cross another constraint.
+	ErrConstraintCrossing = errors.New("constraint crosses another constraint")
+	// ErrRefinementLimit is returned when refining a triangulation would add more points than allowed.
+	ErrRefinementLimit = errors.New("refinement reached its limit of points")
+	// ErrRefinementIncomplete is returned when refining a triangulation leaves triangles that do not meet the bounds,
+	// because they could not be split.
+	ErrRefinementIncomplete = errors.New("refinement left triangles that do not meet its bounds")
+	// ErrInvalidArgument is returned for options out of range, such as a negative area.
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrValueCount is returned when points that should have the same number of values do not.
+	ErrValueCount = errors.New("points have different numbers of values")
+	// ErrDegenerate is returned for input that cannot be triangulated, such as a nil point, coordinates that are
+	// not finite, or locating a point before there are three points that are not collinear.
+	ErrDegenerate = errors.New("degenerate input")
+	// ErrCorrupted is returned when the triangles are not in the state an operation expects. Unless triangles have
+	// been modified directly, it is a bug in this package.
+	ErrCorrupted = errors.New("triangulation is corrupted")
+)
@@ -0,0 +1,121 @@
+package delaunay
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+)
+
+// hilbertOrder returns the indices of the points with the given interleaved x and y coordinates, sorted along a
+// Hilbert curve through their bounding box, so that points near each other in the order are near each other in space.
+func hilbertOrder(coords []float64) []int32 {
+	order := make([]int32, len(coords)/2)
+	for i := range order {
+		order[i] = int32(i)
+	}
+	hilbertSort(order, hilbertKeys(coords))
+	return order
+}
+
+// brioOrder returns the indices of the points with the given interleaved x and y coordinates in a biased randomised
+// insertion order. The points are split into rounds, each about twice the size of the one before, with each point
+// in the last round with probability one half, in the one before with probability one quarter, and so on. Within
+// each round, the points are sorted along a Hilbert curve.
+// The random order keeps the expected cost of building a triangulation low whatever order the points are given in,
+// while sorting each round keeps each point near the one before, so that locating it is quick.
+// Amenta, Choi & Rote, "Incremental constructions con BRIO" (2003).
+func brioOrder(coords []float64, rnd *rand.Rand) []int32 {
+	n := len(coords) / 2
+	rounds := bits.Len(uint(n))
+	round := make([]uint8, n)
+	start := make([]int, rounds+1)
+	for i := range round {
+		// Each coin flip that comes up heads moves the point into an earlier round.
+		heads := bits.TrailingZeros64(rnd.Uint64())
+		if heads > rounds-1 {
+			heads = rounds - 1
+		}
+		round[i] = uint8(rounds - 1 - heads)
+		start[round[i]+1]++
+	}
+	for r := 1; r <= rounds; r++ {
+		start[r] += start[r-1]
+	}
+	order := make([]int32, n)
+	next := append([]int(nil), start...)
+	for i, r := range round {
+		order[next[r]] = int32(i)
+		next[r]++
+	}
+	keys := hilbertKeys(coords)
+	for r := 0; r < rounds; r++ {
+		hilbertSort(order[start[r]:start[r+1]], keys)
+	}
+	return order
+}
+
+// hilbertKeys returns the distance of each of the points with the given interleaved x and y coordinates along a
+// Hilbert curve through their bounding box.
+func hilbertKeys(coords []float64) []uint32 {
+	n := len(coords) / 2
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i := 0; i < n; i++ {
+		minX, maxX = math.Min(minX, coords[2*i]), math.Max(maxX, coords[2*i])
+		minY, maxY = math.Min(minY, coords[2*i+1]), math.Max(maxY, coords[2*i+1])
+	}
+	// Scale both axes equally, so that the curve does not stretch along the narrower one.
+	scale := float64(hilbertSize - 1)
+	if s := math.Max(maxX-minX, maxY-minY); s > 0 {
+		scale /= s
+	}
+	keys := make([]uint32, n)
+	for i := range keys {
+		x := uint32((coords[2*i] - minX) * scale)
+		y := uint32((coords[2*i+1] - minY) * scale)
+		keys[i] = hilbertIndex(x, y)
+	}
+	return keys
+}
+
+// hilbertSort sorts the given point indices by their keys, breaking ties by index so that the order is stable.
+func hilbertSort(order []int32, keys []uint32) {
+	sorted := make([]uint64, len(order))
+	for j, i := range order {
+		sorted[j] = uint64(keys[i])<<32 | uint64(i)
+	}
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+	for j, k := range sorted {
+		order[j] = int32(k & math.MaxUint32)
+	}
+}
+
+// hilbertSize is the number of cells along each side of the grid that hilbertIndex fills.
+const hilbertSize = 1 << 16
+
+// hilbertIndex returns the distance along a Hilbert curve filling a hilbertSize by hilbertSize grid of the cell at
+// column x and row y.
+// https://en.wikipedia.org/wiki/Hilbert_curve
+func hilbertIndex(x, y uint32) uint32 {
+	var d uint32
+	for s := uint32(hilbertSize / 2); s > 0; s /= 2 {
+		var rx, ry uint32
+		if x&s != 0 {
+			rx = 1
+		}
+		if y&s != 0 {
+			ry = 1
+		}
+		d += s * s * ((3 * rx) ^ ry)
+		// Rotate the quadrant so that the curve through it joins up with the curves through its neighbours.
+		if ry == 0 {
+			if rx == 1 {
+				x = s - 1 - x&(s-1)
+				y = s - 1 - y&(s-1)
+			}
+			x, y = y, x
+		}
+	}
+	return d
+}
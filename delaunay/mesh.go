@@ -0,0 +1,409 @@
+package delaunay
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/edwardbrowncross/naturalneighbour/geom"
+)
+
+// Mesh is a delaunay triangulation stored compactly in flat arrays, with points and triangles referred to by their
+// index rather than linked by pointers. It uses much less memory than a Triangulation and is faster to build, but
+// cannot be changed once built.
+// Like a Triangulation, it is completed outside the convex hull by ghost triangles, whose third point is
+// InfiniteIndex. A mesh of n points has 2n-2 triangles, including the ghost triangles.
+type Mesh struct {
+	// Coords holds the x and y coordinates of each point, interleaved.
+	Coords []float64
+	// Triangles holds the indices of the points of each triangle, three per triangle, in clockwise order.
+	// The third point of a ghost triangle is InfiniteIndex, and the outside of the convex hull is clockwise from
+	// the edge between the other two.
+	Triangles []int32
+	// Neighbours holds, for each edge of each triangle, the index of the same edge in the triangle on the other side
+	// of it. Edge e is the edge of triangle e/3 from point Triangles[e] to the next point of that triangle.
+	Neighbours []int32
+	// Incident holds, for each point, the index of an edge starting at it.
+	Incident []int32
+	ghost    int32 // A ghost triangle, from which to follow the convex hull.
+}
+
+// InfiniteIndex stands for the point at infinity in the triangles of a Mesh.
+const InfiniteIndex int32 = -1
+
+// NewMesh builds a compact delaunay triangulation of the points with the given interleaved x and y coordinates.
+// Returns an error wrapping ErrDuplicatePoint if two points have the same coordinates, or ErrDegenerate if there
+// are not three points that are not collinear.
+// Points are referred to by 32 bit indices, so there can be no more than about 350 million of them.
+func NewMesh(coords []float64) (*Mesh, error) {
+	n := len(coords) / 2
+	if len(coords)%2 != 0 {
+		return nil, fmt.Errorf("%w: odd number of coordinates", ErrDegenerate)
+	}
+	if 6*n > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: too many points for a mesh", ErrDegenerate)
+	}
+	for i, c := range coords {
+		if math.IsNaN(c) || math.IsInf(c, 0) {
+			return nil, fmt.Errorf("%w: point %d is not finite", ErrDegenerate, i/2)
+		}
+	}
+	m := &Mesh{
+		Coords:     coords,
+		Triangles:  make([]int32, 0, 6*n),
+		Neighbours: make([]int32, 0, 6*n),
+		Incident:   make([]int32, n),
+	}
+	for i := range m.Incident {
+		m.Incident[i] = -1
+	}
+	// Inserting the points along a space filling curve keeps each one near the last, so finding where it goes is quick
+	// and the arrays are accessed locally.
+	order := hilbertOrder(coords)
+	b := meshBuilder{m: m}
+	seed, err := b.seed(order)
+	if err != nil {
+		return nil, err
+	}
+	b.mark = make([]uint32, 4, 2*n)
+	for j, i := range order {
+		if j == seed[0] || j == seed[1] || j == seed[2] {
+			continue
+		}
+		if err := b.insert(i); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// NumPoints returns the number of points in the mesh.
+func (m *Mesh) NumPoints() int {
+	return len(m.Coords) / 2
+}
+
+// NumTriangles returns the number of triangles in the mesh, including the ghost triangles.
+func (m *Mesh) NumTriangles() int {
+	return len(m.Triangles) / 3
+}
+
+// Point returns the coordinates of the given point.
+func (m *Mesh) Point(i int) (x, y float64) {
+	return m.Coords[2*i], m.Coords[2*i+1]
+}
+
+// IsGhost returns whether the given triangle has the point at infinity as a vertex.
+func (m *Mesh) IsGhost(t int) bool {
+	return m.Triangles[3*t+2] == InfiniteIndex
+}
+
+// Circumcenter returns the coordinates of the circumcenter of the given triangle.
+// The circumcenter of a ghost triangle is at infinity, so NaN is returned.
+func (m *Mesh) Circumcenter(t int) (x, y float64) {
+	if m.IsGhost(t) {
+		return math.NaN(), math.NaN()
+	}
+	ax, ay := m.Point(int(m.Triangles[3*t]))
+	bx, by := m.Point(int(m.Triangles[3*t+1]))
+	cx, cy := m.Point(int(m.Triangles[3*t+2]))
+	return geom.GetCircumcenter(ax, ay, bx, by, cx, cy)
+}
+
+// CircumcircleContains tests whether the given coordinates lie strictly inside the circumcircle of the given
+// triangle. The circumcircle of a ghost triangle is the open half-plane outside its convex hull edge, along with the
+// inside of the edge itself.
+func (m *Mesh) CircumcircleContains(t int, x, y float64) bool {
+	ax, ay := m.Point(int(m.Triangles[3*t]))
+	bx, by := m.Point(int(m.Triangles[3*t+1]))
+	if m.IsGhost(t) {
+		if o := geom.Orient2d(ax, ay, bx, by, x, y); o != 0 {
+			return o < 0
+		}
+		return (x-ax)*(x-bx)+(y-ay)*(y-by) < 0
+	}
+	cx, cy := m.Point(int(m.Triangles[3*t+2]))
+	// The triangle is clockwise, which reverses the sign of the incircle test.
+	return geom.Incircle(ax, ay, bx, by, cx, cy, x, y) < 0
+}
+
+// Hull returns the points of the convex hull of the mesh, in anti-clockwise order.
+func (m *Mesh) Hull() []int32 {
+	hull := []int32{}
+	t := m.ghost
+	for {
+		hull = append(hull, m.Triangles[3*t])
+		// The next ghost triangle shares the edge from the second point of this one to the point at infinity.
+		t = m.Neighbours[3*t+1] / 3
+		if t == m.ghost {
+			return hull
+		}
+	}
+}
+
+// Star returns the triangles around the given point, in clockwise order.
+func (m *Mesh) Star(i int) []int32 {
+	star := []int32{}
+	start := m.Incident[i]
+	for e := start; ; {
+		star = append(star, e/3)
+		// The edge before e in its triangle ends at the point, so the edge opposite that starts at it.
+		e = m.Neighbours[prevEdge(e)]
+		if e == start {
+			return star
+		}
+	}
+}
+
+// Locate finds the triangle of the mesh that contains the given coordinates. Points on the edges of real triangles
+// are inside them. Ghost triangles contain only the points strictly outside their convex hull edge.
+// It walks across the mesh from the nearest of a sample of the points.
+// It does not modify the mesh, so may be called from multiple goroutines at once.
+func (m *Mesh) Locate(x, y float64) (int, error) {
+	return m.LocateFrom(int(m.nearestSample(x, y, m.NumPoints(), m.ghost, math.Inf(1))), x, y)
+}
+
+// LocateFrom is like Locate, but walks across the mesh from the given triangle. This is much faster than Locate
+// when the hint is near the coordinates.
+func (m *Mesh) LocateFrom(hint int, x, y float64) (int, error) {
+	if hint < 0 || hint >= m.NumTriangles() {
+		return -1, fmt.Errorf("%w: no triangle %d", ErrPointNotFound, hint)
+	}
+	t := m.walk(int32(hint), x, y)
+	if t < 0 {
+		return -1, fmt.Errorf("%w: could not find triangle containing point (%f,%f)", ErrCorrupted, x, y)
+	}
+	return int(t), nil
+}
+
+// nearestSample returns a triangle around whichever of a sample of the first n points is nearest to the given
+// coordinates, or the start triangle if none is nearer than the given squared distance.
+// The sample is the cube root of n points, evenly spaced through them.
+// Mücke, Saias & Zhu, "Fast randomized point location without preprocessing in two- and three-dimensional
+// Delaunay triangulations" (1999).
+func (m *Mesh) nearestSample(x, y float64, n int, start int32, best float64) int32 {
+	samples := int(math.Cbrt(float64(n)))
+	for j := 0; j < samples; j++ {
+		q := int32(j * n / samples)
+		qx, qy := m.Point(int(q))
+		if d := (qx-x)*(qx-x) + (qy-y)*(qy-y); d < best {
+			best = d
+			start = m.Incident[q] / 3
+		}
+	}
+	return start
+}
+
+// walk moves across the mesh from triangle t towards the given coordinates, stepping into whichever neighbour lies
+// across an edge that the coordinates are outside of, until it finds the triangle containing them. Returns -1 if it
+// does not arrive in a sensible number of steps. Like Triangle.walk, it tries the edges of each triangle starting
+// from a random one, so that it cannot go round in circles around constraints.
+// See Devillers, Pion & Teillaud, "Walking in a triangulation" (stochastic visibility walk).
+func (m *Mesh) walk(t int32, x, y float64) int32 {
+	state := walkSeed
+	for steps := 0; steps < maxWalkSteps; steps++ {
+		if m.Triangles[3*t+2] == InfiniteIndex {
+			// Ghost triangles are only left for the real triangle inside their convex hull edge.
+			ax, ay := m.Point(int(m.Triangles[3*t]))
+			bx, by := m.Point(int(m.Triangles[3*t+1]))
+			if geom.Orient2d(ax, ay, bx, by, x, y) < 0 {
+				return t
+			}
+			t = m.Neighbours[3*t] / 3
+			continue
+		}
+		next := int32(-1)
+		first := int32(walkFirstEdge(&state))
+		for j := int32(0); j < 3; j++ {
+			k := (first + j) % 3
+			ax, ay := m.Point(int(m.Triangles[3*t+k]))
+			bx, by := m.Point(int(m.Triangles[3*t+(k+1)%3]))
+			// Triangles are clockwise, so points outside of edge ab are anti-clockwise of it.
+			if geom.Orient2d(ax, ay, bx, by, x, y) > 0 {
+				next = m.Neighbours[3*t+k] / 3
+				break
+			}
+		}
+		if next < 0 {
+			return t
+		}
+		t = next
+	}
+	return -1
+}
+
+// nextEdge returns the edge after e in its triangle.
+func nextEdge(e int32) int32 {
+	if e%3 == 2 {
+		return e - 2
+	}
+	return e + 1
+}
+
+// prevEdge returns the edge before e in its triangle.
+func prevEdge(e int32) int32 {
+	if e%3 == 0 {
+		return e + 2
+	}
+	return e - 1
+}
+
+// meshBuilder holds the working state used while building a Mesh.
+type meshBuilder struct {
+	m        *Mesh
+	mark     []uint32 // For each triangle, the insertion for which it was last found to be in the cavity.
+	pass     uint32
+	stack    []int32
+	cavity   []int32
+	boundary []meshEdge
+	fans     []meshEdge
+	last     int32 // A triangle made by the last insertion.
+}
+
+// meshEdge is an edge of the cavity made when inserting a point into a Mesh, from point a to point b. The edge is
+// e in the triangle outside the cavity. In fans, e is instead the edge of a new triangle from the point inserted
+// to a.
+type meshEdge struct {
+	a, b, e int32
+}
+
+// seed makes the first triangle of the mesh, and the ghost triangles around it, from the first three points in the
+// given order that are not collinear. It returns their positions in the order.
+func (b *meshBuilder) seed(order []int32) ([3]int, error) {
+	m := b.m
+	n := len(order)
+	if n < 3 {
+		return [3]int{}, fmt.Errorf("%w: need at least three points but got %d", ErrDegenerate, n)
+	}
+	// Points skipped over here are inserted afterwards like any other.
+	x0, y0 := m.Point(int(order[0]))
+	j1 := 1
+	for ; j1 < n; j1++ {
+		if x, y := m.Point(int(order[j1])); x != x0 || y != y0 {
+			break
+		}
+	}
+	j2 := j1 + 1
+	for ; j2 < n; j2++ {
+		x1, y1 := m.Point(int(order[j1]))
+		x2, y2 := m.Point(int(order[j2]))
+		if geom.Orient2d(x0, y0, x1, y1, x2, y2) != 0 {
+			break
+		}
+	}
+	if j2 >= n {
+		return [3]int{}, fmt.Errorf("%w: all points are collinear", ErrDegenerate)
+	}
+	a, bb, c := order[0], order[j1], order[j2]
+	ax, ay := m.Point(int(a))
+	bx, by := m.Point(int(bb))
+	cx, cy := m.Point(int(c))
+	if !geom.IsClockwise(ax, ay, bx, by, cx, cy) {
+		bb, c = c, bb
+	}
+	// The real triangle, then a ghost triangle on the outside of each of its edges.
+	m.Triangles = append(m.Triangles,
+		a, bb, c,
+		bb, a, InfiniteIndex,
+		c, bb, InfiniteIndex,
+		a, c, InfiniteIndex,
+	)
+	m.Neighbours = append(m.Neighbours,
+		3, 6, 9,
+		0, 11, 7,
+		1, 5, 10,
+		2, 8, 4,
+	)
+	m.Incident[a], m.Incident[bb], m.Incident[c] = 0, 1, 2
+	m.ghost = 1
+	b.last = 0
+	return [3]int{0, j1, j2}, nil
+}
+
+// insert adds point i to the mesh, replacing the triangles whose circumcircles contain it with a fan of triangles
+// around it. The search for where it goes starts from the last triangle made.
+// https://en.wikipedia.org/wiki/Bowyer%E2%80%93Watson_algorithm
+func (b *meshBuilder) insert(i int32) error {
+	m := b.m
+	x, y := m.Point(int(i))
+	t := m.walk(b.last, x, y)
+	if t < 0 {
+		return fmt.Errorf("%w: could not find triangle containing point %d", ErrCorrupted, i)
+	}
+	for k := int32(0); k < 3; k++ {
+		if q := m.Triangles[3*t+k]; q != InfiniteIndex && m.Coords[2*q] == x && m.Coords[2*q+1] == y {
+			return fmt.Errorf("%w: points %d and %d", ErrDuplicatePoint, q, i)
+		}
+	}
+	// Find the cavity, and the edges around it, by searching outwards from the triangle containing the point.
+	b.pass++
+	b.mark[t] = b.pass
+	b.stack = append(b.stack[:0], t)
+	b.cavity = append(b.cavity[:0], t)
+	b.boundary = b.boundary[:0]
+	for len(b.stack) != 0 {
+		c := b.stack[len(b.stack)-1]
+		b.stack = b.stack[:len(b.stack)-1]
+		for e := 3 * c; e < 3*c+3; e++ {
+			o := m.Neighbours[e]
+			adj := o / 3
+			if b.mark[adj] == b.pass {
+				continue
+			}
+			if m.CircumcircleContains(int(adj), x, y) {
+				b.mark[adj] = b.pass
+				b.stack = append(b.stack, adj)
+				b.cavity = append(b.cavity, adj)
+				continue
+			}
+			b.boundary = append(b.boundary, meshEdge{a: m.Triangles[e], b: m.Triangles[nextEdge(e)], e: o})
+		}
+	}
+	// The fan has two more triangles than the cavity. Reuse the slots of the cavity triangles for the rest.
+	for k := 0; k < 2; k++ {
+		b.cavity = append(b.cavity, int32(m.NumTriangles()))
+		m.Triangles = append(m.Triangles, 0, 0, 0)
+		m.Neighbours = append(m.Neighbours, 0, 0, 0)
+		b.mark = append(b.mark, 0)
+	}
+	b.fans = b.fans[:0]
+	for j, edge := range b.boundary {
+		s := b.cavity[j]
+		// Keep the point at infinity last in ghost triangles.
+		first := int32(0)
+		switch InfiniteIndex {
+		case edge.a:
+			first = 1
+		case edge.b:
+			first = 2
+		}
+		vertices := [3]int32{edge.a, edge.b, i}
+		for k := int32(0); k < 3; k++ {
+			v := vertices[(first+k)%3]
+			m.Triangles[3*s+k] = v
+			if v != InfiniteIndex {
+				m.Incident[v] = 3*s + k
+			}
+		}
+		// The edge from a is on the outside of the fan.
+		ea := 3*s + (3-first)%3
+		m.Neighbours[ea] = edge.e
+		m.Neighbours[edge.e] = ea
+		b.fans = append(b.fans, meshEdge{a: edge.a, b: edge.b, e: nextEdge(nextEdge(ea))})
+		if edge.a == InfiniteIndex || edge.b == InfiniteIndex {
+			m.ghost = s
+		}
+	}
+	// Join the fan triangles to each other: the edge from b to the inserted point in each triangle is the edge from
+	// the inserted point to b in the triangle whose outside edge starts at b.
+	for _, f := range b.fans {
+		eb := prevEdge(f.e)
+		for _, g := range b.fans {
+			if g.a == f.b {
+				m.Neighbours[eb] = g.e
+				m.Neighbours[g.e] = eb
+				break
+			}
+		}
+	}
+	b.last = b.cavity[0]
+	return nil
+}
@@ -0,0 +1,153 @@
+package delaunay
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMesh(t *testing.T) {
+	rand.Seed(0)
+	points := make([]*Point, 1000)
+	coords := make([]float64, 0, 2*len(points))
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), 0)
+		coords = append(coords, points[i].X, points[i].Y)
+	}
+	m, err := NewMesh(coords)
+	if err != nil {
+		t.Fatalf("error creating mesh: %v", err)
+	}
+	checkMesh(t, m)
+	// Random points have a unique delaunay triangulation, so the mesh should connect the same points as a
+	// Triangulation does.
+	if _, err := NewTriangulation(points); err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	for i, p := range points {
+		connected := map[int32]bool{}
+		for _, s := range m.Star(i) {
+			for k := 0; k < 3; k++ {
+				if q := m.Triangles[3*s+int32(k)]; q != InfiniteIndex && q != int32(i) {
+					connected[q] = true
+				}
+			}
+		}
+		if len(connected) != len(p.GetConnected()) {
+			t.Errorf("expected point %d to be connected to %d points but got %d", i, len(p.GetConnected()), len(connected))
+		}
+	}
+	if hull := m.Hull(); len(hull) < 3 {
+		t.Errorf("expected a convex hull but got %v", hull)
+	}
+	for i := 0; i < 100; i++ {
+		x, y := 3*rand.Float64()-1, 3*rand.Float64()-1
+		tr, err := m.Locate(x, y)
+		if err != nil {
+			t.Fatalf("error locating point: %v", err)
+		}
+		if !meshTriangleContains(m, tr, x, y) {
+			t.Errorf("expected triangle containing (%v,%v)", x, y)
+		}
+	}
+}
+
+func TestDegenerateMesh(t *testing.T) {
+	grid := []float64{}
+	for i := 0; i < 30; i++ {
+		for j := 0; j < 30; j++ {
+			grid = append(grid, float64(i), float64(j))
+		}
+	}
+	ring := []float64{}
+	for i := 0; i < 64; i++ {
+		s, c := math.Sincos(float64(i) * 2 * math.Pi / 64)
+		ring = append(ring, s, c)
+	}
+	// Collinear points before the first triangle are inserted once there is one.
+	line := []float64{0, 0, 0, 0.5, 0, 1, 0, 2, 0, -1, 1, 1, 0, 3}
+	for name, coords := range map[string][]float64{"grid": grid, "ring": ring, "line": line} {
+		m, err := NewMesh(coords)
+		if err != nil {
+			t.Fatalf("%s: error creating mesh: %v", name, err)
+		}
+		checkMesh(t, m)
+	}
+	if _, err := NewMesh([]float64{0, 0, 1, 1, 2, 2}); !errors.Is(err, ErrDegenerate) {
+		t.Errorf("expected degenerate error for collinear points but got %v", err)
+	}
+	if _, err := NewMesh([]float64{0, 0, 1, 0, 0, 1, 1, 0}); !errors.Is(err, ErrDuplicatePoint) {
+		t.Errorf("expected duplicate point error but got %v", err)
+	}
+}
+
+// checkMesh checks that the triangles of the mesh are linked together consistently and are delaunay.
+func checkMesh(t *testing.T, m *Mesh) {
+	t.Helper()
+	if expected := 2*m.NumPoints() - 2; m.NumTriangles() != expected {
+		t.Errorf("expected %d triangles but got %d", expected, m.NumTriangles())
+	}
+	for e := int32(0); int(e) < len(m.Triangles); e++ {
+		o := m.Neighbours[e]
+		if m.Neighbours[o] != e || m.Triangles[o] != m.Triangles[nextEdge(e)] || m.Triangles[nextEdge(o)] != m.Triangles[e] {
+			t.Fatalf("expected edge %d to be linked to the same edge the other way round", e)
+		}
+		if e%3 != 2 && m.Triangles[e] == InfiniteIndex {
+			t.Errorf("expected point at infinity to be last in triangle %d", e/3)
+		}
+		// The point opposite each edge must be outside the circumcircle of the triangle on the other side.
+		if q := m.Triangles[prevEdge(e)]; q != InfiniteIndex {
+			x, y := m.Point(int(q))
+			if m.CircumcircleContains(int(o/3), x, y) {
+				t.Errorf("expected triangles %d and %d to be delaunay", e/3, o/3)
+			}
+		}
+	}
+	for tr := 0; tr < m.NumTriangles(); tr++ {
+		if m.IsGhost(tr) {
+			continue
+		}
+		ax, ay := m.Point(int(m.Triangles[3*tr]))
+		bx, by := m.Point(int(m.Triangles[3*tr+1]))
+		cx, cy := m.Point(int(m.Triangles[3*tr+2]))
+		if (bx-ax)*(cy-ay)-(by-ay)*(cx-ax) >= 0 {
+			t.Errorf("expected triangle %d to be clockwise", tr)
+		}
+	}
+	for i, e := range m.Incident {
+		if m.Triangles[e] != int32(i) {
+			t.Errorf("expected incident edge of point %d to start at it", i)
+		}
+	}
+}
+
+// meshTriangleContains returns whether the given triangle of the mesh contains the given coordinates.
+func meshTriangleContains(m *Mesh, tr int, x, y float64) bool {
+	ax, ay := m.Point(int(m.Triangles[3*tr]))
+	bx, by := m.Point(int(m.Triangles[3*tr+1]))
+	if m.IsGhost(tr) {
+		return (bx-ax)*(y-ay)-(by-ay)*(x-ax) < 0
+	}
+	cx, cy := m.Point(int(m.Triangles[3*tr+2]))
+	return (bx-ax)*(y-ay)-(by-ay)*(x-ax) <= 0 && (cx-bx)*(y-by)-(cy-by)*(x-bx) <= 0 && (ax-cx)*(y-cy)-(ay-cy)*(x-cx) <= 0
+}
+
+var meshResult *Mesh
+
+func benchmarkMesh(n int, b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		coords := make([]float64, 2*n)
+		for j := range coords {
+			coords[j] = rand.Float64()
+		}
+		b.StartTimer()
+		meshResult, _ = NewMesh(coords)
+	}
+}
+
+func BenchmarkMesh1000(b *testing.B)    { benchmarkMesh(1000, b) }
+func BenchmarkMesh100000(b *testing.B)  { benchmarkMesh(100000, b) }
+func BenchmarkMesh1000000(b *testing.B) { benchmarkMesh(1000000, b) }
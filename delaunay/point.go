@@ -1,13 +1,19 @@
 package delaunay
 
-import "errors"
+import (
+	"fmt"
+	"math"
+)
 
 // Point represents a vertex in the delauany triangulation.
 type Point struct {
-	X         float64     // X location of point.
-	Y         float64     // Y location of point.
-	Value     float64     // Value associated with point.
-	Triangles []*Triangle // The triangles (leaf nodes only) this point is a vertex of.
+	X           float64     // X location of point.
+	Y           float64     // Y location of point.
+	Value       float64     // Value associated with point.
+	Values      []float64   // All values associated with point, if it has more than one. The first is Value.
+	Triangles   []*Triangle // The triangles (leaf nodes only) this point is a vertex of.
+	infinite    bool        // Whether this is the symbolic point at infinity shared by the ghost triangles.
+	constraints []*Point    // The points joined to this one by constraints of the triangulation.
 }
 
 // NewPoint creates a new Point object.
@@ -20,6 +26,56 @@ func NewPoint(x, y, value float64) *Point {
 	}
 }
 
+// newInfinitePoint creates the symbolic point at infinity of a triangulation. Its coordinates are NaN.
+func newInfinitePoint() *Point {
+	p := NewPoint(math.NaN(), math.NaN(), 0)
+	p.infinite = true
+	return p
+}
+
+// IsInfinite returns whether this is the symbolic point at infinity that joins the edges of the convex hull of a
+// triangulation into ghost triangles, rather than a point that was added to it.
+func (p *Point) IsInfinite() bool {
+	return p.infinite
+}
+
+// NewPointWithValues creates a new Point object with several values associated with it.
+// Value is set to the first of the values.
+func NewPointWithValues(x, y float64, values ...float64) *Point {
+	p := NewPoint(x, y, 0)
+	if len(values) > 0 {
+		p.Value = values[0]
+	}
+	p.Values = values
+	return p
+}
+
+// GetValue returns the value of the point in the given channel: Values[channel], or Value if Values is not set.
+func (p *Point) GetValue(channel int) float64 {
+	if len(p.Values) == 0 {
+		return p.Value
+	}
+	return p.Values[channel]
+}
+
+// SetValue sets the value of the point in the given channel, keeping Value and Values[0] the same.
+func (p *Point) SetValue(channel int, value float64) {
+	if len(p.Values) != 0 {
+		p.Values[channel] = value
+	}
+	if channel == 0 {
+		p.Value = value
+	}
+}
+
+// GetNumValues returns the number of values associated with the point.
+func (p *Point) GetNumValues() int {
+	if len(p.Values) == 0 {
+		return 1
+	}
+	return len(p.Values)
+}
+
 // addTriangle adds a new triangle to the point's triangle array.
 func (p *Point) addTriangle(t *Triangle) {
 	p.Triangles = append(p.Triangles, t)
@@ -31,22 +87,26 @@ func (p *Point) removeTriangle(t *Triangle) error {
 	for i := lt; i >= 0; i-- {
 		if p.Triangles[i] == t {
 			p.Triangles[i] = p.Triangles[lt]
+			// Clear the old last element, so that the triangle removed is not kept from being freed.
+			p.Triangles[lt] = nil
 			p.Triangles = p.Triangles[:lt]
 			return nil
 		}
 	}
-	return errors.New("triangle not found")
+	return fmt.Errorf("%w: triangle not found", ErrCorrupted)
 }
 
 // GetConnected gets a list of points that this point is connected to by an edge of the delaunay triangulation.
 // Equivilently, these are the points whos voronoi cells share an edge with this point's voronoi cell.
+// No edge crosses a constraint, so neither do the connections.
+// The point at infinity is not included.
 func (p *Point) GetConnected() (r []*Point) {
 	seen := map[*Point]bool{
 		p: true,
 	}
 	for _, t := range p.Triangles {
 		for _, pt := range t.Points {
-			if seen[pt] {
+			if seen[pt] || pt.infinite {
 				continue
 			}
 			r = append(r, pt)
@@ -0,0 +1,501 @@
+package delaunay
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/edwardbrowncross/naturalneighbour/geom"
+)
+
+// maxRefineAngle is the largest minimum angle, in degrees, that Refine accepts. Beyond about this, refinement
+// rarely finishes.
+const maxRefineAngle = 34
+
+// RefineOptions configures Triangulation.Refine.
+type RefineOptions struct {
+	// MinAngle is the smallest angle, in degrees, that triangles should have. Angles up to about 20.7 degrees can
+	// always be reached, and angles up to about 33 degrees usually can be, though with many more points. Larger
+	// angles are rejected. Where two constraints, or two edges of the convex hull, meet at a small angle, the
+	// triangles in it are left as they are where improving them would never finish. If zero, the angles of triangles
+	// are not bounded.
+	MinAngle float64
+	// MaxArea is the largest area that triangles should have. If zero, the areas of triangles are not bounded.
+	MaxArea float64
+	// MaxPoints is the most points that may be added. If zero, up to a hundred times as many points as the
+	// triangulation has may be added.
+	MaxPoints int
+	// Values returns the values to give a point added at the given coordinates, such as by interpolating those of
+	// the points around it. It is called before the point is added. If nil, added points are given as many values as
+	// the points of the triangulation have, all zero.
+	Values func(x, y float64) ([]float64, error)
+}
+
+// Refine adds points to the triangulation until every triangle meets the bounds of the given options, making a
+// quality mesh for finite element or finite volume methods, and returns the points it added.
+// Constraints and the edges of the convex hull are segments that must stay edges of the mesh, though they may be
+// split. A segment with a point inside its diametral circle is split in two. Otherwise, the circumcenter of each
+// triangle that does not meet the bounds is added, unless it would be inside the diametral circle of a segment, in
+// which case the segment is split instead. Segments are split at their midpoints, or at a power of two from an end
+// they share with other segments, so that the splits on segments meeting at a sharp angle match. Rounding may leave
+// the points splitting edges of the hull a tiny distance inside them.
+// A triangle is left unsplit, though it does not meet the bounds, if its circumcenter would only encroach upon
+// segments that are no longer edges of the mesh, or if its circumcenter is within half its circumradius of a point
+// of the mesh, which can only be one that a constraint hides from the triangle.
+// Returns an error wrapping ErrRefinementLimit, along with the points added, if more than MaxPoints would be added.
+// Otherwise, if any triangle left unsplit still does not meet the bounds once refinement finishes, returns an error
+// wrapping ErrRefinementIncomplete, giving how many there are, along with the points added.
+// Undo functions for points added before refinement will no longer work.
+// Ruppert, "A Delaunay refinement algorithm for quality 2-dimensional mesh generation" (1995); Shewchuk, "Delaunay
+// refinement algorithms for triangular mesh generation" (2002).
+func (t *Triangulation) Refine(opts RefineOptions) ([]*Point, error) {
+	if !(opts.MinAngle >= 0 && opts.MinAngle <= maxRefineAngle) {
+		return nil, fmt.Errorf("%w: minimum angle of %v degrees", ErrInvalidArgument, opts.MinAngle)
+	}
+	if !(opts.MaxArea >= 0) {
+		return nil, fmt.Errorf("%w: maximum area of %v", ErrInvalidArgument, opts.MaxArea)
+	}
+	if opts.MaxPoints < 0 {
+		return nil, fmt.Errorf("%w: maximum of %d points", ErrInvalidArgument, opts.MaxPoints)
+	}
+	if t.isFlat() {
+		return nil, fmt.Errorf("%w: triangulation has no three points that are not collinear", ErrDegenerate)
+	}
+	points := t.getPoints()
+	r := refiner{
+		t:        t,
+		opts:     opts,
+		maxRatio: math.Inf(1),
+		limit:    opts.MaxPoints,
+		channels: points[0].GetNumValues(),
+		origin:   map[*Point][2]*Point{},
+	}
+	if opts.MinAngle > 0 {
+		// The smallest angle of a triangle is the one opposite its shortest edge, whose length is twice its
+		// circumradius times the sine of that angle.
+		r.maxRatio = 1 / (2 * math.Sin(opts.MinAngle*math.Pi/180))
+	}
+	if r.limit == 0 {
+		r.limit = 100 * len(points)
+	}
+	seen := map[*Triangle]bool{}
+	for _, p := range points {
+		for _, s := range p.Triangles {
+			if !seen[s] {
+				seen[s] = true
+				r.queue(s)
+			}
+		}
+	}
+	err := r.run()
+	return r.points, err
+}
+
+// refiner holds the state of a Triangulation being refined.
+type refiner struct {
+	t         *Triangulation
+	opts      RefineOptions
+	maxRatio  float64              // Largest ratio of a triangle's circumradius to its shortest edge.
+	limit     int                  // Most points that may be added.
+	channels  int                  // Number of values of each point.
+	points    []*Point             // Points added.
+	segments  [][2]*Point          // Segments that may be encroached upon.
+	triangles []*Triangle          // Triangles that may not meet the bounds.
+	unsplit   []*Triangle          // Triangles that did not meet the bounds but could not be split.
+	origin    map[*Point][2]*Point // Ends of the segment, as it was before refinement, that each point splitting one lies on.
+}
+
+// run splits encroached segments and bad triangles, segments first, until there are none left.
+func (r *refiner) run() error {
+	for len(r.segments) != 0 || len(r.triangles) != 0 {
+		if len(r.segments) != 0 {
+			e := r.segments[0]
+			r.segments = r.segments[1:]
+			if r.isEncroached(e[0], e[1]) {
+				if _, err := r.splitSegment(e[0], e[1]); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		s := r.triangles[0]
+		r.triangles = r.triangles[1:]
+		if s.isLeaf() && r.isBad(s) {
+			if err := r.splitTriangle(s); err != nil {
+				return err
+			}
+		}
+	}
+	// Splitting other triangles may since have removed those that could not be split.
+	seen := map[*Triangle]bool{}
+	left := 0
+	for _, s := range r.unsplit {
+		if !seen[s] && s.isLeaf() && r.isBad(s) {
+			left++
+		}
+		seen[s] = true
+	}
+	if left != 0 {
+		return fmt.Errorf("%w: %d triangles", ErrRefinementIncomplete, left)
+	}
+	return nil
+}
+
+// queue adds a triangle, and the segments among its edges, to those to check. Ghost triangles are not checked,
+// though the edges of the hull are through the real triangles on them.
+func (r *refiner) queue(s *Triangle) {
+	if s.IsGhost() {
+		return
+	}
+	r.triangles = append(r.triangles, s)
+	for k := 0; k < 3; k++ {
+		if a, b := s.Points[k], s.Points[(k+1)%3]; isSegment(s, a, b) {
+			r.segments = append(r.segments, [2]*Point{a, b})
+		}
+	}
+}
+
+// isSegment returns whether the edge from a to b of the real triangle s is a constraint or an edge of the hull.
+func isSegment(s *Triangle, a, b *Point) bool {
+	if a.IsConstrainedTo(b) {
+		return true
+	}
+	adj := s.GetAdjacentTo(a, b)
+	return adj != nil && adj.IsGhost()
+}
+
+// isInDiametralCircle returns whether the given coordinates lie strictly inside the circle whose diameter is the
+// segment from a to b.
+func isInDiametralCircle(a, b *Point, x, y float64) bool {
+	return (x-a.X)*(x-b.X)+(y-a.Y)*(y-b.Y) < 0
+}
+
+// isEncroached returns whether the segment from a to b is still an edge of the triangulation, and a point lies
+// inside its diametral circle. If any point does, one of those opposite it in the triangles on it does.
+func (r *refiner) isEncroached(a, b *Point) bool {
+	s := getTriangleWithEdge(a, b)
+	if s == nil {
+		return false
+	}
+	adj := s.GetAdjacentTo(a, b)
+	if s.IsGhost() {
+		s, adj = adj, s
+	}
+	if adj == nil || !isSegment(s, a, b) {
+		return false
+	}
+	for _, u := range []*Triangle{s, adj} {
+		if v := u.Points[3-u.indexOf(a)-u.indexOf(b)]; !v.infinite && isInDiametralCircle(a, b, v.X, v.Y) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBad returns whether the real triangle s is larger than the maximum area, or has an angle smaller than the
+// minimum angle that can be improved.
+func (r *refiner) isBad(s *Triangle) bool {
+	if s.IsGhost() {
+		return false
+	}
+	a, b, c := s.getPoints()
+	area := math.Abs(geom.Det3s(a.X, a.Y, b.X, b.Y, c.X, c.Y)) / 2
+	if r.opts.MaxArea > 0 && area > r.opts.MaxArea {
+		return true
+	}
+	if math.IsInf(r.maxRatio, 1) || area == 0 {
+		return false
+	}
+	// The circumradius is the product of the lengths of the edges divided by four times the area.
+	edges := [3][2]*Point{{a, b}, {b, c}, {c, a}}
+	lengths := [3]float64{}
+	shortest := 0
+	for k, e := range edges {
+		lengths[k] = (e[1].X-e[0].X)*(e[1].X-e[0].X) + (e[1].Y-e[0].Y)*(e[1].Y-e[0].Y)
+		if lengths[k] < lengths[shortest] {
+			shortest = k
+		}
+	}
+	ratio := lengths[(shortest+1)%3] * lengths[(shortest+2)%3] / (16 * area * area)
+	if ratio <= r.maxRatio*r.maxRatio {
+		return false
+	}
+	return !r.isSeditious(edges[shortest][0], edges[shortest][1])
+}
+
+// isSeditious returns whether the edge from u to w joins points splitting two segments that meet at an angle
+// smaller than 60 degrees, at the same distance from where they meet. Splitting the triangles on such edges only
+// makes smaller ones with the same small angle, so would never finish.
+func (r *refiner) isSeditious(u, w *Point) bool {
+	ou, okU := r.origin[u]
+	ow, okW := r.origin[w]
+	if !okU || !okW || ou == ow {
+		return false
+	}
+	for _, v := range ou {
+		if v != ow[0] && v != ow[1] {
+			continue
+		}
+		// Each segment's other end.
+		x, y := ou[0], ow[0]
+		if x == v {
+			x = ou[1]
+		}
+		if y == v {
+			y = ow[1]
+		}
+		du, dw := math.Hypot(u.X-v.X, u.Y-v.Y), math.Hypot(w.X-v.X, w.Y-v.Y)
+		dot := (x.X-v.X)*(y.X-v.X) + (x.Y-v.Y)*(y.Y-v.Y)
+		cos := dot / (math.Hypot(x.X-v.X, x.Y-v.Y) * math.Hypot(y.X-v.X, y.Y-v.Y))
+		return math.Abs(du-dw) <= 1e-6*math.Max(du, dw) && cos > 0.5
+	}
+	return false
+}
+
+// splitTriangle adds the circumcenter of the real triangle s, or splits the segments it would encroach upon
+// instead.
+func (r *refiner) splitTriangle(s *Triangle) error {
+	x, y := s.GetCircumcenter()
+	leaf, encroached, err := getEncroached(s, x, y)
+	if err != nil {
+		return err
+	}
+	if len(encroached) != 0 {
+		split := false
+		for _, e := range encroached {
+			ok, err := r.splitSegment(e[0], e[1])
+			if err != nil {
+				return err
+			}
+			split = split || ok
+		}
+		// The triangle may still be bad once the segments have moved out of the way.
+		if split {
+			r.triangles = append(r.triangles, s)
+		} else {
+			r.unsplit = append(r.unsplit, s)
+		}
+		return nil
+	}
+	// No point of a delaunay triangulation is inside a triangle's circumcircle unless a segment hides it from the
+	// triangle. Adding the circumcenter right by such a point would only make worse triangles, so the triangle is
+	// left as it is.
+	a := s.Points[0]
+	radius2 := (x-a.X)*(x-a.X) + (y-a.Y)*(y-a.Y)
+	for _, v := range leaf.Points {
+		if (x-v.X)*(x-v.X)+(y-v.Y)*(y-v.Y) < radius2/4 {
+			r.unsplit = append(r.unsplit, s)
+			return nil
+		}
+	}
+	p, err := r.newPoint(x, y)
+	if err != nil {
+		return err
+	}
+	if _, err := r.t.addPoint(p, false); err != nil {
+		return err
+	}
+	r.added(p)
+	return nil
+}
+
+// getEncroached returns the leaf triangle containing the given coordinates, the circumcenter of the real triangle
+// s, and the segments that a point added there would encroach upon: the first segment between s and the
+// circumcenter if there is one, in which case the leaf is not found, or else those on the edge of the
+// circumcenter's cavity whose diametral circles contain it.
+func getEncroached(s *Triangle, x, y float64) (*Triangle, [][2]*Point, error) {
+	a, b, c := s.getPoints()
+	gx, gy := (a.X+b.X+c.X)/3, (a.Y+b.Y+c.Y)/3
+	p := &Point{X: x, Y: y}
+	// Walk from the middle of s towards the circumcenter.
+	for {
+		if in, _ := s.Contains(p); in {
+			break
+		}
+		var next *Triangle
+		for k := 0; k < 3 && next == nil; k++ {
+			u, w := s.Points[k], s.Points[(k+1)%3]
+			// Triangles are clockwise, so the circumcenter is beyond the edge uw if it is anti-clockwise of it.
+			if geom.Orient2d(u.X, u.Y, w.X, w.Y, x, y) <= 0 {
+				continue
+			}
+			// The line leaves through the edge with u to its left and w to its right. A vertex on the line counts as
+			// being to its left, so that the walk does not go round it.
+			if geom.Orient2d(gx, gy, x, y, u.X, u.Y) < 0 || geom.Orient2d(gx, gy, x, y, w.X, w.Y) >= 0 {
+				continue
+			}
+			if isSegment(s, u, w) {
+				return nil, [][2]*Point{{u, w}}, nil
+			}
+			next = s.GetAdjacentTo(u, w)
+		}
+		if next == nil {
+			return nil, nil, fmt.Errorf("%w: could not walk towards (%f,%f)", ErrCorrupted, x, y)
+		}
+		s = next
+	}
+	// Search the triangles whose circumcircles contain the circumcenter, without crossing segments.
+	leaf := s
+	encroached := [][2]*Point{}
+	cavity := map[*Triangle]bool{s: true}
+	triangles := []*Triangle{s}
+	for i := 0; i < len(triangles); i++ {
+		s := triangles[i]
+		for k := 0; k < 3; k++ {
+			u, w := s.Points[k], s.Points[(k+1)%3]
+			if isSegment(s, u, w) {
+				if isInDiametralCircle(u, w, x, y) {
+					encroached = append(encroached, [2]*Point{u, w})
+				}
+				continue
+			}
+			if adj := s.GetAdjacentTo(u, w); adj != nil && !cavity[adj] && adj.CircumcircleContains(x, y) {
+				cavity[adj] = true
+				triangles = append(triangles, adj)
+			}
+		}
+	}
+	return leaf, encroached, nil
+}
+
+// splitSegment adds a point splitting the segment from a to b, if it is still an edge of the triangulation,
+// returning whether it did.
+func (r *refiner) splitSegment(a, b *Point) (bool, error) {
+	s := getTriangleWithEdge(a, b)
+	if s == nil {
+		return false, nil
+	}
+	adj := s.GetAdjacentTo(a, b)
+	if s.IsGhost() {
+		s, adj = adj, s
+	}
+	// Inside the hull, only constraints are segments.
+	if !adj.IsGhost() && !a.IsConstrainedTo(b) {
+		return false, nil
+	}
+	// Rounding can put the point just off the segment, which is fine as long as each of the triangles it makes turns
+	// the same way as the one it is part of. On the hull, the ghost triangles are split along with the edge, and the
+	// point is kept from going outside it, where it would leave the points either side of it inside the hull and
+	// make flat triangles of them. Just inside it, the point stays on the hull, which is then very slightly out of
+	// shape.
+	x, y := r.getSplit(a, b)
+	c, d := s.GetPointOpposite(adj), adj.GetPointOpposite(s)
+	if d.infinite {
+		// Step across the edge towards c.
+		nx, ny := a.Y-b.Y, b.X-a.X
+		if nx*(c.X-a.X)+ny*(c.Y-a.Y) < 0 {
+			nx, ny = -nx, -ny
+		}
+		side := geom.Orient2d(a.X, a.Y, b.X, b.Y, c.X, c.Y)
+		for n := 0; geom.Orient2d(a.X, a.Y, b.X, b.Y, x, y)*side < 0; n++ {
+			if n == 64 {
+				return false, nil
+			}
+			x, y = math.Nextafter(x, x+nx), math.Nextafter(y, y+ny)
+		}
+	}
+	if !isSplittable(a, b, c, d, x, y) {
+		return false, nil
+	}
+	p, err := r.newPoint(x, y)
+	if err != nil {
+		return false, err
+	}
+	if _, err := s.InsertOnEdge(p, a, b); err != nil {
+		return false, err
+	}
+	if a.IsConstrainedTo(b) {
+		newConstraintSplitter(a, b, p)
+	}
+	if err := r.t.flipAround(p, d.infinite, false, nil); err != nil {
+		return false, err
+	}
+	if r.t.location == LocateWalk {
+		r.t.remember(p)
+	}
+	r.added(p, a, b)
+	return true, nil
+}
+
+// isSplittable returns whether a point at the given coordinates lies strictly inside the quadrilateral made by the
+// triangles either side of the edge from a to b, whose other vertices are c and d, and so close to the edge that
+// the triangles it makes with each side of the quadrilateral do not overlap. If d is the point at infinity, it only
+// needs to lie between a and b as seen from c.
+func isSplittable(a, b, c, d *Point, x, y float64) bool {
+	opposite := func(o1, o2 float64) bool {
+		return o1 < 0 && o2 > 0 || o1 > 0 && o2 < 0
+	}
+	for _, e := range []*Point{c, d} {
+		if !e.infinite && !opposite(geom.Orient2d(e.X, e.Y, a.X, a.Y, x, y), geom.Orient2d(e.X, e.Y, b.X, b.Y, x, y)) {
+			return false
+		}
+	}
+	if d.infinite {
+		return true
+	}
+	for _, e := range []*Point{a, b} {
+		if !opposite(geom.Orient2d(x, y, e.X, e.Y, c.X, c.Y), geom.Orient2d(x, y, e.X, e.Y, d.X, d.Y)) {
+			return false
+		}
+	}
+	return true
+}
+
+// getSplit returns where to split the segment from a to b: at a power of two from its end if only one of its ends
+// is an end of a segment as it was before refinement, and otherwise at its midpoint.
+// Splitting segments that share an end at powers of two from it puts the points splitting them at the same
+// distances from it, so that the triangles between them have the angle between the segments, and no smaller one.
+func (r *refiner) getSplit(a, b *Point) (x, y float64) {
+	_, splitA := r.origin[a]
+	_, splitB := r.origin[b]
+	if splitA == splitB {
+		return (a.X + b.X) / 2, (a.Y + b.Y) / 2
+	}
+	if splitA {
+		a, b = b, a
+	}
+	length := math.Hypot(b.X-a.X, b.Y-a.Y)
+	f := math.Exp2(math.Round(math.Log2(length/2))) / length
+	return a.X + f*(b.X-a.X), a.Y + f*(b.Y-a.Y)
+}
+
+// newPoint creates a point to add at the given coordinates, with values from the options.
+// Returns an error wrapping ErrRefinementLimit if no more points may be added.
+func (r *refiner) newPoint(x, y float64) (*Point, error) {
+	if len(r.points) >= r.limit {
+		return nil, fmt.Errorf("%w: %d points added", ErrRefinementLimit, len(r.points))
+	}
+	if r.opts.Values == nil {
+		if r.channels == 1 {
+			return NewPoint(x, y, 0), nil
+		}
+		return NewPointWithValues(x, y, make([]float64, r.channels)...), nil
+	}
+	values, err := r.opts.Values(x, y)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != r.channels {
+		return nil, fmt.Errorf("%w: point at (%f,%f) given %d values but the points have %d", ErrValueCount, x, y,
+			len(values), r.channels)
+	}
+	return NewPointWithValues(x, y, values...), nil
+}
+
+// added records a point that has been added, splitting the segment from a to b if given, and queues the triangles
+// around it, and the segments on them, to be checked.
+func (r *refiner) added(p *Point, segment ...*Point) {
+	r.points = append(r.points, p)
+	if len(segment) == 2 {
+		a, b := segment[0], segment[1]
+		if o, ok := r.origin[a]; ok {
+			r.origin[p] = o
+		} else if o, ok := r.origin[b]; ok {
+			r.origin[p] = o
+		} else {
+			r.origin[p] = [2]*Point{a, b}
+		}
+	}
+	for _, s := range p.Triangles {
+		r.queue(s)
+	}
+}
@@ -1,13 +1,15 @@
 package delaunay
 
 import (
-	"errors"
 	"fmt"
+	"math"
 
 	"github.com/edwardbrowncross/naturalneighbour/geom"
 )
 
 // Triangle is a triangle within the delaunay triangulation.
+// A ghost triangle has the point at infinity as one of its vertices. It stands for the open half-plane outside the
+// edge of the convex hull between its other two vertices.
 type Triangle struct {
 	Points   [3]*Point   // Vertices of triangle.
 	Children []*Triangle // Triangles created by splitting of this triangle.
@@ -16,7 +18,8 @@ type Triangle struct {
 // NewTriangle creates a new triangle object with the three points as vertices.
 func NewTriangle(p1, p2, p3 *Point) *Triangle {
 	// Points should always be defined in clockwise order. Some things break if not.
-	if !geom.IsClockwise(p1.X, p1.Y, p2.X, p2.Y, p3.X, p3.Y) {
+	// Ghost triangles have no geometric order, so must be given in the right order.
+	if !p1.infinite && !p2.infinite && !p3.infinite && !geom.IsClockwise(p1.X, p1.Y, p2.X, p2.Y, p3.X, p3.Y) {
 		p1, p2 = p2, p1
 	}
 	t := &Triangle{
@@ -35,38 +38,105 @@ func (t *Triangle) getPoints() (*Point, *Point, *Point) {
 	return t.Points[0], t.Points[1], t.Points[2]
 }
 
+// IsGhost returns whether the triangle has the point at infinity as a vertex.
+func (t *Triangle) IsGhost() bool {
+	return t.Points[0].infinite || t.Points[1].infinite || t.Points[2].infinite
+}
+
+// ghostEdge returns the vertices at the ends of the convex hull edge of a ghost triangle, ordered so that the
+// half-plane the ghost triangle stands for is clockwise from the edge, as though the point at infinity were there.
+// The third return value is false if t is not a ghost triangle, or has more than one infinite vertex.
+func (t *Triangle) ghostEdge() (a, b *Point, ok bool) {
+	infinite := 0
+	for k, p := range t.Points {
+		if p.infinite {
+			infinite++
+			a, b = t.Points[(k+1)%3], t.Points[(k+2)%3]
+		}
+	}
+	return a, b, infinite == 1
+}
+
+// indexOf returns the position of p in the vertices of t, or -1 if it is not one of them.
+func (t *Triangle) indexOf(p *Point) int {
+	for k, q := range t.Points {
+		if q == p {
+			return k
+		}
+	}
+	return -1
+}
+
 // Contains tests whether the given point's X and Y values lie inside the bounds of this triangle.
+// Points on the edges of real triangles are inside them. Ghost triangles contain only the points strictly outside
+// their convex hull edge.
 func (t *Triangle) Contains(p *Point) (bool, error) {
 	if p == nil {
-		return false, errors.New("unable to test triangle for containing nil point")
+		return false, fmt.Errorf("%w: unable to test triangle for containing nil point", ErrDegenerate)
+	}
+	if t.IsGhost() {
+		a, b, ok := t.ghostEdge()
+		// The root of the triangle tree is made only of the point at infinity, and contains everything.
+		return !ok || geom.Orient2d(a.X, a.Y, b.X, b.Y, p.X, p.Y) < 0, nil
 	}
 	t1, t2, t3 := t.getPoints()
 	// Test that the line between every vertex and the given point is clockwise
 	// from the line from said vertex to the next vertex in the triangle.
 	// (Assumes triangle defined in clockwise order).
-	return geom.Det2(t1.X-p.X, t1.Y-p.Y, t2.X-p.X, t2.Y-p.Y) <= 0 &&
-		geom.Det2(t2.X-p.X, t2.Y-p.Y, t3.X-p.X, t3.Y-p.Y) <= 0 &&
-		geom.Det2(t3.X-p.X, t3.Y-p.Y, t1.X-p.X, t1.Y-p.Y) <= 0, nil
+	return geom.Orient2d(t1.X, t1.Y, t2.X, t2.Y, p.X, p.Y) <= 0 &&
+		geom.Orient2d(t2.X, t2.Y, t3.X, t3.Y, p.X, p.Y) <= 0 &&
+		geom.Orient2d(t3.X, t3.Y, t1.X, t1.Y, p.X, p.Y) <= 0, nil
 }
 
 // GetCircumcenter returns the coordinates of the circumcenter of this triangle.
-// Adapted from https://gist.github.com/mutoo/5617691.
+// The circumcenter of a ghost triangle is at infinity, so NaN is returned.
 func (t *Triangle) GetCircumcenter() (x, y float64) {
+	if t.IsGhost() {
+		return math.NaN(), math.NaN()
+	}
 	p1, p2, p3 := t.getPoints()
-	m1 := p1.X*p1.X + p1.Y*p1.Y
-	m2 := p2.X*p2.X + p2.Y*p2.Y
-	m3 := p3.X*p3.X + p3.Y*p3.Y
-	f := 1 / (2 * geom.Det3s(p1.X, p1.Y, p2.X, p2.Y, p3.X, p3.Y))
-	x = f * geom.Det3s(m1, p1.Y, m2, p2.Y, m3, p3.Y)
-	y = -f * geom.Det3s(m1, p1.X, m2, p2.X, m3, p3.X)
-	return
+	return geom.GetCircumcenter(p1.X, p1.Y, p2.X, p2.Y, p3.X, p3.Y)
+}
+
+// CircumcircleContains tests whether the given coordinates lie strictly inside the circumcircle of this triangle.
+// Coordinates exactly on the circumcircle are not inside it.
+// The circumcircle of a ghost triangle is the open half-plane outside its convex hull edge, along with the inside of
+// the edge itself, as the limit of the circles through the edge as their centers move outwards.
+func (t *Triangle) CircumcircleContains(x, y float64) bool {
+	if t.IsGhost() {
+		a, b, ok := t.ghostEdge()
+		if !ok {
+			return false
+		}
+		if o := geom.Orient2d(a.X, a.Y, b.X, b.Y, x, y); o != 0 {
+			return o < 0
+		}
+		return (x-a.X)*(x-b.X)+(y-a.Y)*(y-b.Y) < 0
+	}
+	a, b, c := t.getPoints()
+	// The triangle is clockwise, which reverses the sign of the incircle test.
+	return geom.Incircle(a.X, a.Y, b.X, b.Y, c.X, c.Y, x, y) < 0
+}
+
+// isLeaf returns whether the triangle is part of the triangulation, rather than having been split, flipped or
+// removed from it.
+func (t *Triangle) isLeaf() bool {
+	if len(t.Children) != 0 {
+		return false
+	}
+	for _, s := range t.Points[0].Triangles {
+		if s == t {
+			return true
+		}
+	}
+	return false
 }
 
 // getChildContaining tests each of this triangles children and returns the one that contains the given point.
 // If the triangle has no children, it returns nil.
 func (t *Triangle) getChildContaining(p *Point) (*Triangle, error) {
 	if p == nil {
-		return nil, errors.New("unable to test children for containing nil point")
+		return nil, fmt.Errorf("%w: unable to test children for containing nil point", ErrDegenerate)
 	}
 	for _, c := range t.Children {
 		if pInC, _ := c.Contains(p); pInC {
@@ -80,7 +150,7 @@ func (t *Triangle) getChildContaining(p *Point) (*Triangle, error) {
 // If the point is not in this triangle, returns nil.
 func (t *Triangle) Search(p *Point) (leaf *Triangle, err error) {
 	if p == nil {
-		return nil, errors.New("unable to test children for containing nil point")
+		return nil, fmt.Errorf("%w: unable to test children for containing nil point", ErrDegenerate)
 	}
 	if pInT, _ := t.Contains(p); !pInT {
 		return nil, nil
@@ -95,6 +165,64 @@ func (t *Triangle) Search(p *Point) (leaf *Triangle, err error) {
 	return
 }
 
+// walk moves across the triangulation from this leaf triangle towards the given coordinates, stepping into
+// whichever neighbour lies across an edge that the coordinates are outside of, until it finds the leaf triangle
+// containing them. Returns nil if it walks off the edge of the triangulation or does not arrive in a sensible
+// number of steps.
+// The edges of each triangle are tried starting from a random one. Always trying them in the same order can go
+// round in circles where constraints keep the triangulation from being delaunay.
+// See Devillers, Pion & Teillaud, "Walking in a triangulation" (stochastic visibility walk).
+func (t *Triangle) walk(x, y float64) *Triangle {
+	cur := t
+	state := walkSeed
+	for steps := 0; steps < maxWalkSteps; steps++ {
+		var next *Triangle
+		if a, b, ok := cur.ghostEdge(); ok {
+			// Ghost triangles are only left for the real triangle inside their convex hull edge.
+			if geom.Orient2d(a.X, a.Y, b.X, b.Y, x, y) < 0 {
+				return cur
+			}
+			if cur = cur.GetAdjacentTo(a, b); cur == nil {
+				return nil
+			}
+			continue
+		}
+		first := walkFirstEdge(&state)
+		for j := 0; j < 3; j++ {
+			k := (first + j) % 3
+			a, b := cur.Points[k], cur.Points[(k+1)%3]
+			// Triangles are clockwise, so points outside of edge ab are anti-clockwise of it.
+			if geom.Orient2d(a.X, a.Y, b.X, b.Y, x, y) > 0 {
+				next = cur.GetAdjacentTo(a, b)
+				if next == nil {
+					return nil
+				}
+				break
+			}
+		}
+		if next == nil {
+			return cur
+		}
+		cur = next
+	}
+	return nil
+}
+
+// maxWalkSteps is the number of triangles walk will cross before giving up.
+const maxWalkSteps = 1 << 16
+
+// walkSeed is the state each walk's random choices start from. Each walk keeps its own, so walks can run at once.
+const walkSeed uint32 = 2463534242
+
+// walkFirstEdge advances the random state of a walk and returns which edge of a triangle to try first.
+// Marsaglia, "Xorshift RNGs" (2003).
+func walkFirstEdge(state *uint32) int {
+	*state ^= *state << 13
+	*state ^= *state >> 17
+	*state ^= *state << 5
+	return int(*state % 3)
+}
+
 // Insert splits this triangle into three new triangles, with the given point as a central vertex.
 func (t *Triangle) Insert(p *Point) error {
 	// Update triangles points are linked to.
@@ -114,13 +242,13 @@ func (t *Triangle) Insert(p *Point) error {
 // Uninsert undoes an Insert operation, removing the child triangles created and removing the point from the triangulation.
 func (t *Triangle) Uninsert() error {
 	if len(t.Children) != 3 {
-		return errors.New("can only uninsert from a triangle previously inserted into")
+		return fmt.Errorf("%w: can only uninsert from a triangle previously inserted into", ErrCorrupted)
 	}
 	c1 := t.Children[0]
 	c2 := t.Children[1]
 	c3 := t.Children[2]
 	if len(c1.Children) != 0 || len(c2.Children) != 0 || len(c3.Children) != 0 {
-		return errors.New("cannot uninsert triangle whos children have been split")
+		return fmt.Errorf("%w: cannot uninsert triangle whos children have been split", ErrCorrupted)
 	}
 	// Update triangles points are linked to.
 	for _, p := range c1.Points {
@@ -140,11 +268,67 @@ func (t *Triangle) Uninsert() error {
 	return nil
 }
 
+// InsertOnEdge splits this triangle, and the triangle on the other side of its edge from a to b if there is one,
+// into two new triangles each, with the given point, which lies on that edge, as a common vertex.
+// It returns the triangle on the other side of the edge, or nil if the edge is on the outside of the triangulation.
+func (t *Triangle) InsertOnEdge(p, a, b *Point) (*Triangle, error) {
+	if t.indexOf(a) < 0 || t.indexOf(b) < 0 || a == b {
+		return nil, fmt.Errorf("%w: can only insert on an edge of the triangle", ErrCorrupted)
+	}
+	t2 := t.GetAdjacentTo(a, b)
+	for _, s := range []*Triangle{t, t2} {
+		if s == nil {
+			continue
+		}
+		// Update triangles points are linked to.
+		for _, q := range s.Points {
+			if err := q.removeTriangle(s); err != nil {
+				return nil, err
+			}
+		}
+		// Keep the order of the vertices of s, so that ghost triangles stay the right way round.
+		k := 3 - s.indexOf(a) - s.indexOf(b)
+		c, u, w := s.Points[k], s.Points[(k+1)%3], s.Points[(k+2)%3]
+		s.Children = []*Triangle{
+			NewTriangle(c, u, p),
+			NewTriangle(c, p, w),
+		}
+	}
+	return t2, nil
+}
+
+// UninsertOnEdge undoes an InsertOnEdge operation, given the triangle it returned, removing the child triangles
+// created and removing the point from the triangulation.
+func (t *Triangle) UninsertOnEdge(t2 *Triangle) error {
+	triangles := []*Triangle{t}
+	if t2 != nil {
+		triangles = append(triangles, t2)
+	}
+	for _, s := range triangles {
+		if len(s.Children) != 2 || len(s.Children[0].Children) != 0 || len(s.Children[1].Children) != 0 {
+			return fmt.Errorf("%w: can only uninsert on edge from unsplit triangles previously inserted into", ErrCorrupted)
+		}
+	}
+	for _, s := range triangles {
+		// Update triangles points are linked to.
+		for _, c := range s.Children {
+			for _, q := range c.Points {
+				q.removeTriangle(c)
+			}
+		}
+		for _, q := range s.Points {
+			q.addTriangle(s)
+		}
+		s.Children = []*Triangle{}
+	}
+	return nil
+}
+
 // FlipWith takes two triangles that share a common edge and creates two new triangles, which together
 // form the same quadrilateral, but whos common edge stretches between the two preiously unconnected points.
 func (t1 *Triangle) FlipWith(t2 *Triangle) error {
 	if t2 == nil {
-		return errors.New("cannot flip with nil triangle")
+		return fmt.Errorf("%w: cannot flip with nil triangle", ErrCorrupted)
 	}
 	// Determine which vertices are shared between the triangles.
 	common := []*Point{}
@@ -175,28 +359,25 @@ func (t1 *Triangle) FlipWith(t2 *Triangle) error {
 		}
 	}
 	if len(unique) != 2 || len(common) != 2 {
-		return fmt.Errorf("cannot flip triangles that do not share an edge (%d, %d)", len(unique), len(common))
+		return fmt.Errorf("%w: cannot flip triangles that do not share an edge (%d, %d)", ErrCorrupted, len(unique), len(common))
 	}
 	// Update point -> triangle reverences.
 	for _, p := range t1.Points {
 		if err := p.removeTriangle(t1); err != nil {
-			return fmt.Errorf("Failed to remove triangle from point: %v", err)
+			return fmt.Errorf("Failed to remove triangle from point: %w", err)
 		}
 	}
 	for _, p := range t2.Points {
 		if err := p.removeTriangle(t2); err != nil {
-			return fmt.Errorf("Failed to remove triangle from point: %v", err)
+			return fmt.Errorf("Failed to remove triangle from point: %w", err)
 		}
 	}
-	// Create new triangles.
-	var t3, t4 *Triangle
-	if geom.IsClockwise(unique[0].X, unique[0].Y, unique[1].X, unique[1].Y, common[0].X, common[0].Y) {
-		t3 = NewTriangle(unique[0], unique[1], common[0])
-		t4 = NewTriangle(unique[1], unique[0], common[1])
-	} else {
-		t3 = NewTriangle(unique[0], unique[1], common[1])
-		t4 = NewTriangle(unique[1], unique[0], common[0])
-	}
+	// Create new triangles. Going clockwise around the quadrilateral from the unique point of t1 gives the common
+	// points in the order they are in t1, so the new triangles can be made clockwise without testing, which also
+	// works for ghost triangles.
+	k := t1.indexOf(unique[0])
+	t3 := NewTriangle(unique[0], t1.Points[(k+1)%3], unique[1])
+	t4 := NewTriangle(unique[1], t1.Points[(k+2)%3], unique[0])
 	t1.Children = []*Triangle{t3, t4}
 	t2.Children = []*Triangle{t3, t4}
 	return nil
@@ -205,15 +386,15 @@ func (t1 *Triangle) FlipWith(t2 *Triangle) error {
 // UnflipWith reverses a Flip operation, deleting the created child triangles from t1 and t2.
 func (t1 *Triangle) UnflipWith(t2 *Triangle) error {
 	if t2 == nil {
-		return errors.New("cannot unflip with nil triangle")
+		return fmt.Errorf("%w: cannot unflip with nil triangle", ErrCorrupted)
 	}
 	if t1.Children[0] != t2.Children[0] && t1.Children[0] != t2.Children[1] {
-		return errors.New("cannot unflip with triangle that was not created in the same flip operation")
+		return fmt.Errorf("%w: cannot unflip with triangle that was not created in the same flip operation", ErrCorrupted)
 	}
 	c1 := t1.Children[0]
 	c2 := t1.Children[1]
 	if len(c1.Children) != 0 || len(c2.Children) != 0 {
-		return errors.New("cannot unflip triangles whos children have been split")
+		return fmt.Errorf("%w: cannot unflip triangles whos children have been split", ErrCorrupted)
 	}
 	// Update point -> triangle links.
 	for _, p := range c1.Points {
@@ -236,17 +417,17 @@ func (t1 *Triangle) UnflipWith(t2 *Triangle) error {
 
 // IsDelaunayWith tests wheth the two involved triangles are locally delaunay.
 // It does this by checking whether the opposing point of one triangle lies within the circumradius of the other triangle.
+// Triangles either side of a constraint are always locally delaunay, as the constraint hides each from the other.
 func (t1 *Triangle) IsDelaunayWith(t2 *Triangle) bool {
 	p := t2.GetPointOpposite(t1)
-	if p == nil {
+	// The point at infinity is outside every circle.
+	if p == nil || p.infinite {
+		return true
+	}
+	if k := t1.indexOf(t1.GetPointOpposite(t2)); k >= 0 && t1.Points[(k+1)%3].IsConstrainedTo(t1.Points[(k+2)%3]) {
 		return true
 	}
-	// http://www.cs.utah.edu/~csilva/courses/cpsc7960/pdf/boulos-DT.pdf (slide 7).
-	a, b, c := t1.getPoints()
-	lenP := p.X*p.X + p.Y*p.Y
-	return geom.Det3(a.X-p.X, a.Y-p.Y, a.X*a.X+a.Y*a.Y-lenP,
-		b.X-p.X, b.Y-p.Y, b.X*b.X+b.Y*b.Y-lenP,
-		c.X-p.X, c.Y-p.Y, c.X*c.X+c.Y*c.Y-lenP) >= 0
+	return !t1.CircumcircleContains(p.X, p.Y)
 }
 
 // GetTriangleOpposite takes one of the vertices of this triangle and returns the triangle bordering the edge of the
@@ -283,14 +464,13 @@ func (t1 *Triangle) GetPointOpposite(t2 *Triangle) *Point {
 
 // GetAdjacentTo takes two vertices from this triangle and returns the triangle adjoining this triangle along that edge.
 func (t *Triangle) GetAdjacentTo(p1, p2 *Point) *Triangle {
+	// Search the triangles of whichever point has fewer, since the point at infinity has very many.
+	if len(p1.Triangles) > len(p2.Triangles) {
+		p1, p2 = p2, p1
+	}
 	for _, t1 := range p1.Triangles {
-		if t1 == t {
-			continue
-		}
-		for _, t2 := range p2.Triangles {
-			if t1 == t2 {
-				return t1
-			}
+		if t1 != t && t1.indexOf(p2) >= 0 {
+			return t1
 		}
 	}
 	return nil
@@ -1,33 +1,179 @@
 package delaunay
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+
+	"github.com/edwardbrowncross/naturalneighbour/geom"
 )
 
 // Triangulation represents a delaunay triangulation.
+// Outside the convex hull of its points, it is completed by ghost triangles, each joining an edge of the hull to a
+// symbolic point at infinity, so that every location is in exactly one triangle and points can be added anywhere.
+// Until it has three points that are not collinear, it has no triangles at all.
 type Triangulation struct {
-	Root *Triangle
+	Root     *Triangle // Root of the triangle tree. Its vertices are all the point at infinity. It has no children unless locating with LocateHistory.
+	Infinite *Point    // The point at infinity shared by the ghost triangles.
+	flat     []*Point  // Points added while there were no three that were not collinear.
+	location Location
+	samples  int
+	points   []*Point  // Points to sample when locating with LocateWalk. May include points that have since been removed.
+	last     *Triangle // A triangle made by the last insertion, for LocateWalk to start from.
+}
+
+// Location selects how a Triangulation finds the triangle containing a point.
+type Location int
+
+const (
+	// LocateHistory searches the tree of every triangle that has been part of the triangulation, each linked to the
+	// triangles it was split or flipped into. Searches take logarithmic time, but the tree grows with every change.
+	LocateHistory Location = iota
+	// LocateWalk walks across the triangulation towards the point, starting from whichever of a sample of points is
+	// nearest to it. Triangles that are replaced are not kept, so it uses much less memory than LocateHistory.
+	LocateWalk
+)
+
+// Options configures a Triangulation.
+type Options struct {
+	// Duplicates selects what happens when several of the points have the same coordinates.
+	// Defaults to DuplicateError.
+	Duplicates DuplicatePolicy
+	// Location selects how points are located. Defaults to LocateHistory.
+	Location Location
+	// Samples is the number of points LocateWalk compares to choose where to start walking from.
+	// If zero, the cube root of the number of points is used. If negative, walks start from the last triangle made.
+	Samples int
+	// Order selects the order the points are inserted in. Defaults to OrderBRIO.
+	Order Order
+	// Construction selects how the triangulation is built. Defaults to ConstructIncremental.
+	Construction Construction
+	// Workers is the number of goroutines ConstructDivideAndConquer spreads its work across. The triangulation is
+	// the same however many there are. If zero, runtime.GOMAXPROCS(0) is used.
+	Workers int
 }
 
-// NewTriangulation creates a new triangulation object.
-// Given points should to be randomly sorted for optimum efficicency of triangle tree.
+// Construction selects how NewTriangulationWithOptions builds a triangulation.
+type Construction int
+
+const (
+	// ConstructIncremental inserts the points one at a time, in the order selected by Options.Order.
+	ConstructIncremental Construction = iota
+	// ConstructDivideAndConquer builds the whole triangulation at once by divide and conquer, which takes
+	// O(n log n) time whatever the points, with far fewer allocations, and can use several goroutines. For points
+	// with a unique delaunay triangulation, such as any with no four on a circle, it makes the same triangles as
+	// ConstructIncremental. Options.Order is ignored.
+	// It makes no triangle tree, so the triangulation locates points with LocateWalk.
+	ConstructDivideAndConquer
+)
+
+// Order selects the order NewTriangulationWithOptions inserts points in.
+type Order int
+
+const (
+	// OrderBRIO inserts the points in a biased randomised insertion order, in rounds of increasing size that are
+	// each sorted along a Hilbert curve. This keeps construction fast however the points are given, even if they
+	// are sorted. The order is the same every time for the same points, so the triangulation is too.
+	OrderBRIO Order = iota
+	// OrderGiven inserts the points in the order they are given. Points that are sorted, or that are otherwise
+	// close together in space for long runs, make construction much slower.
+	OrderGiven
+)
+
+// brioSeed seeds the random numbers used by OrderBRIO, so that the order is reproducible.
+const brioSeed = 1
+
+// NewTriangulation creates a new triangulation object using default options.
 func NewTriangulation(points []*Point) (*Triangulation, error) {
-	// Create a single root triangle that contains all the given points.
+	return NewTriangulationWithOptions(points, Options{})
+}
+
+// NewTriangulationWithOptions creates a new triangulation object using the given options.
+// Points left out because of Options.Duplicates have no triangles.
+func NewTriangulationWithOptions(points []*Point, opts Options) (*Triangulation, error) {
+	inf := newInfinitePoint()
 	t := Triangulation{
-		Root: getBoundingTriangle(points),
+		Root: &Triangle{
+			Points:   [3]*Point{inf, inf, inf},
+			Children: []*Triangle{},
+		},
+		Infinite: inf,
+		location: opts.Location,
+		samples:  opts.Samples,
 	}
-	// Add each point to the triangulation one at a time.
+	// Duplicates are caught as they are added if they are errors, without the cost of looking for them first.
+	if opts.Duplicates != DuplicateError {
+		// Looking for duplicates reads every point, so check them all first.
+		for _, p := range points {
+			if err := checkPoint(p); err != nil {
+				return nil, err
+			}
+		}
+		var err error
+		if points, err = dedupe(points, opts.Duplicates); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Construction == ConstructDivideAndConquer {
+		workers := opts.Workers
+		if workers <= 0 {
+			workers = runtime.GOMAXPROCS(0)
+		}
+		if err := t.buildDivideAndConquer(points, workers); err != nil {
+			return nil, err
+		}
+		return &t, nil
+	}
+	ordered := points
+	if opts.Order == OrderBRIO {
+		var err error
+		if ordered, err = brioPoints(points); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.addPoints(ordered); err != nil {
+		// Report the same duplicate as inserting the points in the given order would.
+		if opts.Order == OrderBRIO && errors.Is(err, ErrDuplicatePoint) {
+			return nil, firstDuplicate(points)
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// addPoints adds each of the given points to the triangulation one at a time, in the order given.
+func (t *Triangulation) addPoints(points []*Point) error {
 	for _, p := range points {
 		if _, err := t.addPoint(p, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// brioPoints returns the given points in a biased randomised insertion order. Returns an error for the first point
+// that could not be added to a triangulation, as their coordinates are needed to sort them.
+func brioPoints(points []*Point) ([]*Point, error) {
+	coords := make([]float64, 0, 2*len(points))
+	for _, p := range points {
+		if err := checkPoint(p); err != nil {
 			return nil, err
 		}
+		coords = append(coords, p.X, p.Y)
 	}
-	return &t, nil
+	order := brioOrder(coords, rand.New(rand.NewSource(brioSeed)))
+	ordered := make([]*Point, len(points))
+	for j, i := range order {
+		ordered[j] = points[i]
+	}
+	return ordered, nil
 }
 
 // AddPoint adds a new point to the delaunay triangulation and returns a function that will remove said point again.
-// If point is not inside the bounding triangle created at the start, returns an error.
+// Points can be added anywhere. If another point already has the same coordinates, returns a *DuplicatePointError.
 func (t *Triangulation) AddPoint(p *Point) (Undo, error) {
 	return t.addPoint(p, true)
 }
@@ -35,24 +181,90 @@ func (t *Triangulation) AddPoint(p *Point) (Undo, error) {
 // addPoint adds a new point to the delaunay triangulation and optionally returns a function that will remove said point again.
 // http://web.mit.edu/alexmv/Public/6.850-lectures/lecture09.pdf
 func (t *Triangulation) addPoint(p *Point, undoable bool) (Undo, error) {
+	if err := checkPoint(p); err != nil {
+		return nil, err
+	}
+	var undo Undo
+	var err error
+	if t.isFlat() {
+		undo, err = t.addFlatPoint(p, undoable)
+	} else {
+		undo, err = t.insert(p, undoable)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.location == LocateWalk {
+		t.remember(p)
+	}
+	return undo, nil
+}
+
+// checkPoint returns an error if the given point cannot be added to a triangulation.
+func checkPoint(p *Point) error {
+	if p == nil {
+		return fmt.Errorf("%w: nil point", ErrDegenerate)
+	}
+	if math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsInf(p.X, 0) || math.IsInf(p.Y, 0) {
+		return fmt.Errorf("%w: point (%f,%f) is not finite", ErrDegenerate, p.X, p.Y)
+	}
+	return nil
+}
+
+// insert adds a new point to a triangulation that has triangles, and optionally returns a function that will remove
+// said point again.
+func (t *Triangulation) insert(p *Point, undoable bool) (Undo, error) {
 	var ul undoList
 	// Find leaf triangle to insert new point into.
-	leaf, err := t.Root.Search(p)
+	leaf, err := t.locate(p)
 	if err != nil {
-		return nil, fmt.Errorf("error finding leaf triangle: %v", err)
+		return nil, err
 	}
-	if leaf == nil {
-		return nil, fmt.Errorf("point (%f,%f) does not lie within bounds", p.X, p.Y)
+	// Insert into leaf triangle, or into the edge it lies on, so that no triangle is ever flat.
+	// Ghost triangles only contain points strictly outside the hull, so never have points on their edges.
+	var onEdge [2]*Point
+	for k, a := range leaf.Points {
+		if a.X == p.X && a.Y == p.Y {
+			return nil, &DuplicatePointError{Point: p, Existing: a}
+		}
+		b := leaf.Points[(k+1)%3]
+		if !leaf.IsGhost() && geom.Orient2d(a.X, a.Y, b.X, b.Y, p.X, p.Y) == 0 {
+			onEdge = [2]*Point{a, b}
+		}
 	}
-	// Insert into leaf triangle.
-	if err := leaf.Insert(p); err != nil {
-		return nil, err
+	if onEdge[0] != nil {
+		t2, err := leaf.InsertOnEdge(p, onEdge[0], onEdge[1])
+		if err != nil {
+			return nil, err
+		}
+		if undoable {
+			ul.Add(newEdgeUninserter(leaf, t2))
+		}
+		if onEdge[0].IsConstrainedTo(onEdge[1]) {
+			splitter := newConstraintSplitter(onEdge[0], onEdge[1], p)
+			if undoable {
+				ul.Add(splitter)
+			}
+		}
+	} else {
+		if err := leaf.Insert(p); err != nil {
+			return nil, err
+		}
+		if undoable {
+			ul.Add(newUninserter(leaf))
+		}
 	}
-	if undoable {
-		ul = undoList{}
-		ul.Add(newUninserter(leaf))
+	if err := t.flipAround(p, false, undoable, &ul); err != nil {
+		return nil, err
 	}
-	// Check each of the three new triangles for being locally delaunay.
+	return ul.Undo, nil
+}
+
+// flipAround flips the edges opposite a point that has just been added until the triangles around it are delaunay,
+// adding the flips to the undo list if undoable. If onHull is set, the point was added on an edge of the hull, so
+// is on the hull already, and the edges between ghost triangles are not flipped to bring it there.
+func (t *Triangulation) flipAround(p *Point, onHull, undoable bool, ul *undoList) error {
+	// Check each of the new triangles for being locally delaunay.
 	toCheck := make([]*Triangle, len(p.Triangles))
 	copy(toCheck, p.Triangles)
 	for i := 0; i < len(toCheck); i++ {
@@ -61,12 +273,12 @@ func (t *Triangulation) addPoint(p *Point, undoable bool) (Undo, error) {
 		if t2 == nil {
 			continue
 		}
-		if t1.IsDelaunayWith(t2) {
+		if t1.IsDelaunayWith(t2) || onHull && t1.IsGhost() && t2.IsGhost() {
 			continue
 		}
 		// Flip any triangles not delaunay.
 		if err := t1.FlipWith(t2); err != nil {
-			return nil, fmt.Errorf("could not flip triangles: %v", err)
+			return fmt.Errorf("could not flip triangles: %w", err)
 		}
 		if undoable {
 			ul.Add(newUnflipper(t1, t2))
@@ -74,43 +286,566 @@ func (t *Triangulation) addPoint(p *Point, undoable bool) (Undo, error) {
 		// Add the new triangles to the list of triangles to check.
 		toCheck = append(toCheck, t1.Children[0], t1.Children[1])
 	}
+	t.last = p.Triangles[0]
+	return nil
+}
+
+// isFlat returns whether the triangulation has no triangles, because it does not have three points that are not
+// collinear.
+func (t *Triangulation) isFlat() bool {
+	return len(t.Infinite.Triangles) == 0
+}
+
+// addFlatPoint adds a point to a triangulation that has no triangles. If the point is not collinear with those
+// already added, the first triangle is made from it and two of them, and the rest are added to that.
+func (t *Triangulation) addFlatPoint(p *Point, undoable bool) (Undo, error) {
+	var ul undoList
+	for _, q := range t.flat {
+		if q.X == p.X && q.Y == p.Y {
+			return nil, &DuplicatePointError{Point: p, Existing: q}
+		}
+	}
+	if len(t.flat) < 2 || geom.Orient2d(t.flat[0].X, t.flat[0].Y, t.flat[1].X, t.flat[1].Y, p.X, p.Y) == 0 {
+		t.flat = append(t.flat, p)
+		if undoable {
+			ul.Add(newFlatRemover(t, p))
+		}
+		return ul.Undo, nil
+	}
+	flat := t.flat
+	t.flat = nil
+	first := NewTriangle(flat[0], flat[1], p)
+	children := []*Triangle{first}
+	for k := 0; k < 3; k++ {
+		// Each edge of the first triangle is an edge of the hull, with a ghost triangle on the other side of it.
+		a, b := first.Points[k], first.Points[(k+1)%3]
+		children = append(children, NewTriangle(b, a, t.Infinite))
+	}
+	if t.location == LocateHistory {
+		t.Root.Children = children
+	}
+	t.last = first
+	if undoable {
+		ul.Add(newFlattener(t, flat))
+	}
+	for _, q := range flat[2:] {
+		if _, err := t.insert(q, false); err != nil {
+			return nil, err
+		}
+	}
 	return ul.Undo, nil
 }
 
-// getBounds gets the minimum and maximum x and y coordinates of any points in the given array.
-func getBounds(points []*Point) (minX, minY, maxX, maxY float64) {
-	minX = math.Inf(+1)
-	minY = math.Inf(+1)
-	maxX = math.Inf(-1)
-	maxY = math.Inf(-1)
+// flatten removes every triangle from the triangulation, and every constraint, returning the points that were in it.
+func (t *Triangulation) flatten() []*Point {
+	points := t.getPoints()
 	for _, p := range points {
-		if p.X < minX {
-			minX = p.X
+		p.Triangles = []*Triangle{}
+		p.constraints = nil
+	}
+	t.Infinite.Triangles = []*Triangle{}
+	t.Root.Children = []*Triangle{}
+	t.last = nil
+	return points
+}
+
+// getPoints returns the points of the triangles of the triangulation, found by searching outwards from the point
+// at infinity.
+func (t *Triangulation) getPoints() []*Point {
+	points := []*Point{}
+	seen := map[*Point]bool{t.Infinite: true}
+	toVisit := []*Point{t.Infinite}
+	for i := 0; i < len(toVisit); i++ {
+		for _, s := range toVisit[i].Triangles {
+			for _, q := range s.Points {
+				if !seen[q] {
+					seen[q] = true
+					toVisit = append(toVisit, q)
+					points = append(points, q)
+				}
+			}
 		}
-		if p.X > maxX {
-			maxX = p.X
+	}
+	return points
+}
+
+// DiscardHistory drops the tree of triangles that have been replaced, freeing the memory they use, and locates
+// points with LocateWalk from then on. Undo functions returned before it is called still work.
+func (t *Triangulation) DiscardHistory() {
+	t.Root.Children = []*Triangle{}
+	if t.location == LocateWalk {
+		return
+	}
+	t.location = LocateWalk
+	t.points = append(t.getPoints(), t.flat...)
+}
+
+// remember adds p to the points sampled by LocateWalk.
+func (t *Triangulation) remember(p *Point) {
+	if len(t.points) == cap(t.points) {
+		// Drop points that have been removed, or remembered twice, before growing, so they are not kept forever.
+		seen := make(map[*Point]bool, len(t.points))
+		kept := t.points[:0]
+		for _, q := range t.points {
+			if !seen[q] && t.HasPoint(q) {
+				seen[q] = true
+				kept = append(kept, q)
+			}
 		}
-		if p.Y < minY {
-			minY = p.Y
+		for k := len(kept); k < len(t.points); k++ {
+			t.points[k] = nil
 		}
-		if p.Y > maxY {
-			maxY = p.Y
+		t.points = kept
+	}
+	t.points = append(t.points, p)
+}
+
+// removeFlatPoint removes a point added while the triangulation had no triangles, returning whether it was found.
+func (t *Triangulation) removeFlatPoint(p *Point) bool {
+	for i, q := range t.flat {
+		if q == p {
+			t.flat = append(t.flat[:i], t.flat[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// RemovePoint removes a point from the delaunay triangulation at any time, filling the hole left behind with new
+// delaunay triangles.
+// Unlike the Undo function returned by AddPoint, it does not need points to be removed in the reverse order to
+// which they were added. Undo functions for points added before the removal will no longer work.
+// The constraints that end at the point are removed too, unless it splits a straight constraint in two, which is
+// then joined back up.
+func (t *Triangulation) RemovePoint(p *Point) error {
+	constrained := p.GetConstrained()
+	if err := t.removePoint(p); err != nil {
+		return err
+	}
+	if len(constrained) == 2 && !t.isFlat() {
+		a, b := constrained[0], constrained[1]
+		if isOnSegment(a, b, p) {
+			return t.AddConstraint(a, b)
 		}
 	}
-	return
+	return nil
 }
 
-// getBoundingTriangle returns a triangle that will encompass all the given points.
-func getBoundingTriangle(points []*Point) *Triangle {
-	minX, minY, maxX, maxY := getBounds(points)
-	cx := (minX + maxX) / 2
-	cy := (minY + maxY) / 2
-	s := math.Max(maxX-minX, maxY-minY) / 2
-	return NewTriangle(
-		NewPoint(cx, cy+3*s, 0),
-		NewPoint(cx+3*s, cy, 0),
-		NewPoint(cx-3*s, cy-3*s, 0),
-	)
+// removePoint removes a point from the delaunay triangulation, along with the constraints that end at it.
+func (t *Triangulation) removePoint(p *Point) error {
+	if p.infinite {
+		return fmt.Errorf("%w: cannot remove it", ErrBoundingPoint)
+	}
+	if t.removeFlatPoint(p) {
+		return nil
+	}
+	if len(p.Triangles) == 0 {
+		return fmt.Errorf("%w: (%f,%f)", ErrPointNotFound, p.X, p.Y)
+	}
+	// Find the polygon formed by the points connected to p, in clockwise order around it.
+	star := make([]*Triangle, len(p.Triangles))
+	copy(star, p.Triangles)
+	next := make(map[*Point]*Point, len(star))
+	for _, s := range star {
+		k := s.indexOf(p)
+		next[s.Points[(k+1)%3]] = s.Points[(k+2)%3]
+	}
+	polygon := make([]*Point, 0, len(star))
+	for v := star[0].Points[(star[0].indexOf(p)+1)%3]; len(polygon) < len(star); v = next[v] {
+		if v == nil {
+			return fmt.Errorf("%w: triangles around point do not form a closed polygon", ErrCorrupted)
+		}
+		polygon = append(polygon, v)
+	}
+	unconstrainAll(p)
+	if next[t.Infinite] != nil {
+		return t.removeHullPoint(p, star, polygon)
+	}
+	// Detach the triangles around p from their points, then fill the polygon with new triangles.
+	if err := detach(star); err != nil {
+		return err
+	}
+	filled := fillStarPolygon(p, polygon)
+	// Every point inside the old triangles is inside one of the new ones, so the new triangles can be children
+	// of all of the old ones in the triangle tree.
+	for _, s := range star {
+		s.Children = filled
+	}
+	// Constraints around the polygon can hide its points from each other, leaving no ear that is delaunay, so
+	// whatever ears were cut off instead are flipped until they are constrained delaunay.
+	return restoreDelaunay(filled)
+}
+
+// removeHullPoint removes a point on the convex hull, given the triangles around it and the polygon they form.
+// The polygon includes the point at infinity. The other points of the polygon make a chain between the neighbours
+// of p along the hull, which becomes the new hull wherever it does not turn inwards. The pockets where it does are
+// filled with triangles, which are then flipped until they are delaunay.
+func (t *Triangulation) removeHullPoint(p *Point, star []*Triangle, polygon []*Point) error {
+	// Rotate the polygon so that the point at infinity is last. The chain then runs anti-clockwise around the hull.
+	for !polygon[len(polygon)-1].infinite {
+		polygon = append(polygon[1:], polygon[0])
+	}
+	chain := polygon[:len(polygon)-1]
+	// If the chain is straight and there is nothing on the other side of it, removing p leaves no triangles.
+	flat := true
+	first, last := chain[0], chain[len(chain)-1]
+	for _, s := range star {
+		if s.IsGhost() {
+			continue
+		}
+		k := s.indexOf(p)
+		u, w := s.Points[(k+1)%3], s.Points[(k+2)%3]
+		if adj := s.GetAdjacentTo(u, w); adj == nil || !adj.IsGhost() ||
+			geom.Orient2d(first.X, first.Y, last.X, last.Y, u.X, u.Y) != 0 {
+			flat = false
+			break
+		}
+	}
+	if flat {
+		points := t.flatten()
+		t.flat = make([]*Point, 0, len(points)-1)
+		for _, q := range points {
+			if q != p {
+				t.flat = append(t.flat, q)
+			}
+		}
+		return nil
+	}
+	if err := detach(star); err != nil {
+		return err
+	}
+	// Graham scan along the chain, cutting off every point where it turns clockwise.
+	filled := []*Triangle{}
+	hull := []*Point{first}
+	for _, x := range chain[1:] {
+		for len(hull) >= 2 {
+			a, b := hull[len(hull)-2], hull[len(hull)-1]
+			if !geom.IsClockwise(a.X, a.Y, b.X, b.Y, x.X, x.Y) {
+				break
+			}
+			filled = append(filled, NewTriangle(a, b, x))
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, x)
+	}
+	for k := 0; k+1 < len(hull); k++ {
+		filled = append(filled, NewTriangle(hull[k], hull[k+1], t.Infinite))
+	}
+	for _, s := range star {
+		s.Children = filled
+	}
+	return restoreDelaunay(filled)
+}
+
+// detach removes the given triangles from the lists of triangles of their points.
+func detach(triangles []*Triangle) error {
+	for _, s := range triangles {
+		for _, v := range s.Points {
+			if err := v.removeTriangle(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MovePoint moves a point of the delaunay triangulation to the given coordinates, updating the triangles around it
+// so that the triangulation stays delaunay.
+// If the point stays within the polygon formed by the points it is connected to, the triangles around it are still
+// valid and only need flipping. Otherwise the point is removed and inserted again at its new location.
+// If another point is already at the new location, the point is not moved and a *DuplicatePointError is returned.
+// The constraints that end at the point move with it. If one of them would cross another constraint at the new
+// location, it is removed and an error wrapping ErrConstraintCrossing is returned.
+// Undo functions for points added before the move will no longer work.
+func (t *Triangulation) MovePoint(p *Point, x, y float64) error {
+	if p.infinite {
+		return fmt.Errorf("%w: cannot move it", ErrBoundingPoint)
+	}
+	if !t.HasPoint(p) {
+		return fmt.Errorf("%w: (%f,%f)", ErrPointNotFound, p.X, p.Y)
+	}
+	if len(p.Triangles) != 0 && isInStar(p, x, y) {
+		p.X, p.Y = x, y
+		star := make([]*Triangle, len(p.Triangles))
+		copy(star, p.Triangles)
+		return restoreDelaunay(star)
+	}
+	constrained := p.GetConstrained()
+	if err := t.removePoint(p); err != nil {
+		return err
+	}
+	ox, oy := p.X, p.Y
+	p.X, p.Y = x, y
+	if _, err := t.addPoint(p, false); err != nil {
+		// Put the point back where it was, so that it is not lost from the triangulation.
+		p.X, p.Y = ox, oy
+		if _, e := t.addPoint(p, false); e != nil {
+			return e
+		}
+		if e := t.addConstraints(p, constrained); e != nil {
+			return e
+		}
+		return err
+	}
+	return t.addConstraints(p, constrained)
+}
+
+// addConstraints adds a constraint from p to each of the given points, returning the first error, if any.
+// Constraints are only kept while there are triangles, so none are added if there are none.
+func (t *Triangulation) addConstraints(p *Point, constrained []*Point) error {
+	var err error
+	for _, q := range constrained {
+		if t.isFlat() {
+			return nil
+		}
+		if e := t.AddConstraint(p, q); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// HasPoint returns whether p is one of the points of the triangulation. Points that were left out because of
+// Options.Duplicates, or have been removed, are not.
+func (t *Triangulation) HasPoint(p *Point) bool {
+	return len(p.Triangles) != 0 && !p.infinite || t.isFlatPoint(p)
+}
+
+// GetPoints returns the points of the triangulation, in no particular order. The point at infinity is not included.
+func (t *Triangulation) GetPoints() []*Point {
+	return append(t.getPoints(), t.flat...)
+}
+
+// isFlatPoint returns whether p was added while the triangulation had no triangles, and is still waiting for them.
+func (t *Triangulation) isFlatPoint(p *Point) bool {
+	for _, q := range t.flat {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
+
+// isInStar returns whether the given coordinates lie strictly inside the polygon formed by the points connected to p,
+// on the same side of every edge as p, so that p could be moved there without any of its triangles turning over.
+// A point on the hull is never moved this way, as its ghost triangles would have to change too.
+func isInStar(p *Point, x, y float64) bool {
+	for _, s := range p.Triangles {
+		k := s.indexOf(p)
+		u, w := s.Points[(k+1)%3], s.Points[(k+2)%3]
+		if u.infinite || w.infinite || !geom.IsClockwise(x, y, u.X, u.Y, w.X, w.Y) {
+			return false
+		}
+	}
+	return true
+}
+
+// restoreDelaunay flips edges of the given leaf triangles, and of the triangles created by flipping them, until
+// every one is locally delaunay with its neighbours.
+// Lawson, "Software for C1 surface interpolation" (1977).
+func restoreDelaunay(triangles []*Triangle) error {
+	toCheck := make([]*Triangle, len(triangles))
+	copy(toCheck, triangles)
+	for i := 0; i < len(toCheck); i++ {
+		t1 := toCheck[i]
+		// Triangles that have already been flipped away are no longer part of the triangulation.
+		if len(t1.Children) != 0 {
+			continue
+		}
+		for _, p := range t1.Points {
+			t2 := t1.GetTriangleOpposite(p)
+			if t2 == nil || t1.IsDelaunayWith(t2) || !isConvexWith(t1, t2) {
+				continue
+			}
+			if err := t1.FlipWith(t2); err != nil {
+				return fmt.Errorf("could not flip triangles: %w", err)
+			}
+			toCheck = append(toCheck, t1.Children[0], t1.Children[1])
+			break
+		}
+	}
+	return nil
+}
+
+// isConvexWith returns whether the two neighbouring triangles together form a convex quadrilateral, so that the
+// edge between them can be flipped.
+func isConvexWith(t1, t2 *Triangle) bool {
+	a, b := t1.GetPointOpposite(t2), t2.GetPointOpposite(t1)
+	if a == nil || b == nil || a.infinite || b.infinite {
+		return false
+	}
+	k := t1.indexOf(a)
+	c1, c2 := t1.Points[(k+1)%3], t1.Points[(k+2)%3]
+	// Flipping the edge between a ghost triangle and a real one moves a point off the hull, which is only
+	// possible where the hull turns the wrong way at it.
+	if c2.infinite {
+		return geom.IsClockwise(a.X, a.Y, c1.X, c1.Y, b.X, b.Y)
+	}
+	if c1.infinite {
+		return geom.IsClockwise(b.X, b.Y, c2.X, c2.Y, a.X, a.Y)
+	}
+	// The common edge must cross the line between the two opposite points.
+	o1 := geom.Orient2d(a.X, a.Y, b.X, b.Y, c1.X, c1.Y)
+	o2 := geom.Orient2d(a.X, a.Y, b.X, b.Y, c2.X, c2.Y)
+	return (o1 < 0 && o2 > 0) || (o1 > 0 && o2 < 0)
+}
+
+// fillStarPolygon triangulates the polygon of points that were connected to the removed point p, given in clockwise
+// order, so that the new triangles are delaunay.
+// It repeatedly cuts off an ear (three consecutive points making a clockwise turn) whose circumcircle contains none
+// of the other points of the polygon. The ear whose circumcircle p lies least deep inside is always one, so ears are
+// tried in that order. Constraints can break this, in which case an ear with no other points inside it is cut off
+// instead, and the triangles need flipping afterwards.
+// Devillers, "On deletion in Delaunay triangulations" (1999).
+func fillStarPolygon(p *Point, polygon []*Point) []*Triangle {
+	type ear struct {
+		i     int
+		power float64
+	}
+	filled := make([]*Triangle, 0, len(polygon)-2)
+	for len(polygon) > 3 {
+		n := len(polygon)
+		ears := make([]ear, 0, n)
+		for i := range polygon {
+			a, b, c := polygon[(i+n-1)%n], polygon[i], polygon[(i+1)%n]
+			if !geom.IsClockwise(a.X, a.Y, b.X, b.Y, c.X, c.Y) {
+				continue
+			}
+			// The power of p with respect to the circumcircle of the ear.
+			cx, cy := geom.GetCircumcenter(a.X, a.Y, b.X, b.Y, c.X, c.Y)
+			power := (p.X-cx)*(p.X-cx) + (p.Y-cy)*(p.Y-cy) - (a.X-cx)*(a.X-cx) - (a.Y-cy)*(a.Y-cy)
+			ears = append(ears, ear{i, power})
+		}
+		sort.Slice(ears, func(i, j int) bool { return ears[i].power > ears[j].power })
+		// The powers are only approximate, so check the circumcircle of each ear exactly before using it.
+		best := -1
+		for _, e := range ears {
+			if isEmptyEar(polygon, e.i) {
+				best = e.i
+				break
+			}
+		}
+		for j := 0; best < 0 && j < len(ears); j++ {
+			if isValidEar(polygon, ears[j].i) {
+				best = ears[j].i
+			}
+		}
+		a, b, c := polygon[(best+n-1)%n], polygon[best], polygon[(best+1)%n]
+		filled = append(filled, NewTriangle(a, b, c))
+		polygon = append(polygon[:best], polygon[best+1:]...)
+	}
+	return append(filled, NewTriangle(polygon[0], polygon[1], polygon[2]))
+}
+
+// isEmptyEar returns whether none of the points of the polygon lie strictly inside the circumcircle of the
+// clockwise ear at position i.
+func isEmptyEar(polygon []*Point, i int) bool {
+	n := len(polygon)
+	a, b, c := polygon[(i+n-1)%n], polygon[i], polygon[(i+1)%n]
+	for j := 2; j < n-1; j++ {
+		d := polygon[(i+j)%n]
+		if geom.Incircle(a.X, a.Y, b.X, b.Y, c.X, c.Y, d.X, d.Y) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidEar returns whether none of the other points of the polygon lie inside or on the clockwise ear at position
+// i, so that it can be cut off.
+func isValidEar(polygon []*Point, i int) bool {
+	n := len(polygon)
+	a, b, c := polygon[(i+n-1)%n], polygon[i], polygon[(i+1)%n]
+	for j := 2; j < n-1; j++ {
+		d := polygon[(i+j)%n]
+		if geom.Orient2d(a.X, a.Y, b.X, b.Y, d.X, d.Y) <= 0 && geom.Orient2d(b.X, b.Y, c.X, c.Y, d.X, d.Y) <= 0 &&
+			geom.Orient2d(c.X, c.Y, a.X, a.Y, d.X, d.Y) <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Locate finds the leaf triangle of the triangulation that contains the given coordinates.
+// It does not modify the triangulation, so may be called from multiple goroutines at once.
+func (t *Triangulation) Locate(x, y float64) (*Triangle, error) {
+	return t.locate(&Point{X: x, Y: y})
+}
+
+// LocateFrom finds the leaf triangle of the triangulation that contains the given coordinates, walking across
+// the triangulation from the hint triangle. This is much faster than Locate when the hint is near the coordinates.
+// If the hint is nil or no longer a leaf triangle, it behaves like Locate.
+// It does not modify the triangulation, so may be called from multiple goroutines at once.
+func (t *Triangulation) LocateFrom(hint *Triangle, x, y float64) (*Triangle, error) {
+	if hint == nil || !hint.isLeaf() {
+		return t.Locate(x, y)
+	}
+	if leaf := hint.walk(x, y); leaf != nil {
+		return leaf, nil
+	}
+	return t.Locate(x, y)
+}
+
+// locate finds the leaf triangle that contains the given point.
+func (t *Triangulation) locate(p *Point) (*Triangle, error) {
+	if t.isFlat() {
+		return nil, fmt.Errorf("%w: triangulation has no three points that are not collinear", ErrDegenerate)
+	}
+	if t.location == LocateWalk {
+		if leaf := t.getStart(p.X, p.Y).walk(p.X, p.Y); leaf != nil {
+			return leaf, nil
+		}
+		return nil, fmt.Errorf("%w: could not find leaf triangle containing point (%f,%f)", ErrCorrupted, p.X, p.Y)
+	}
+	leaf, err := t.Root.Search(p)
+	if err != nil {
+		return nil, fmt.Errorf("error finding leaf triangle: %w", err)
+	}
+	if leaf != nil {
+		return leaf, nil
+	}
+	// Moving points changes the shape of old triangles in the tree, so the search can miss. The triangles around the
+	// point at infinity are always leaves, so walk from one of those instead.
+	if leaf := t.Infinite.Triangles[0].walk(p.X, p.Y); leaf != nil {
+		return leaf, nil
+	}
+	return nil, fmt.Errorf("%w: could not find leaf triangle containing point (%f,%f)", ErrCorrupted, p.X, p.Y)
+}
+
+// getStart returns a leaf triangle near the given coordinates for LocateWalk to start walking from: one around the
+// nearest of a sample of points, or the last triangle made if that is nearer.
+// Jump-and-walk: Mücke, Saias & Zhu, "Fast randomized point location without preprocessing in two- and
+// three-dimensional Delaunay triangulations" (1999).
+func (t *Triangulation) getStart(x, y float64) *Triangle {
+	start := t.Infinite.Triangles[0]
+	best := math.Inf(1)
+	if t.last != nil && t.last.isLeaf() {
+		start = t.last
+		for _, q := range t.last.Points {
+			if !q.infinite {
+				best = math.Min(best, (q.X-x)*(q.X-x)+(q.Y-y)*(q.Y-y))
+			}
+		}
+	}
+	n := t.samples
+	if n == 0 {
+		n = int(math.Cbrt(float64(len(t.points))))
+	}
+	if n > len(t.points) {
+		n = len(t.points)
+	}
+	// Sample evenly through the points, so that locating stays deterministic and safe to do concurrently.
+	for j := 0; j < n; j++ {
+		q := t.points[j*len(t.points)/n]
+		if len(q.Triangles) == 0 {
+			continue
+		}
+		if d := (q.X-x)*(q.X-x) + (q.Y-y)*(q.Y-y); d < best {
+			best = d
+			start = q.Triangles[0]
+		}
+	}
+	return start
 }
 
 // Undo is a function that will remove an added point from the triangulation,
@@ -149,6 +884,56 @@ func (u uninserter) Undo() error {
 	return u.t.Uninsert()
 }
 
+type edgeUninserter struct {
+	t1 *Triangle
+	t2 *Triangle
+}
+
+func newEdgeUninserter(t1, t2 *Triangle) edgeUninserter {
+	return edgeUninserter{
+		t1: t1,
+		t2: t2,
+	}
+}
+func (u edgeUninserter) Undo() error {
+	return u.t1.UninsertOnEdge(u.t2)
+}
+
+type flatRemover struct {
+	t *Triangulation
+	p *Point
+}
+
+func newFlatRemover(t *Triangulation, p *Point) flatRemover {
+	return flatRemover{
+		t: t,
+		p: p,
+	}
+}
+func (u flatRemover) Undo() error {
+	if !u.t.removeFlatPoint(u.p) {
+		return fmt.Errorf("%w: (%f,%f)", ErrPointNotFound, u.p.X, u.p.Y)
+	}
+	return nil
+}
+
+type flattener struct {
+	t    *Triangulation
+	flat []*Point
+}
+
+func newFlattener(t *Triangulation, flat []*Point) flattener {
+	return flattener{
+		t:    t,
+		flat: append([]*Point{}, flat...),
+	}
+}
+func (u flattener) Undo() error {
+	u.t.flatten()
+	u.t.flat = append([]*Point{}, u.flat...)
+	return nil
+}
+
 type undoList struct {
 	list []undoer
 }
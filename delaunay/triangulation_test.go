@@ -1,9 +1,14 @@
 package delaunay
 
 import (
+	"errors"
+	"fmt"
 	"math"
 	"math/rand"
+	"sort"
 	"testing"
+
+	"github.com/edwardbrowncross/naturalneighbour/geom"
 )
 
 func TestTriangulation(t *testing.T) {
@@ -23,9 +28,918 @@ func TestTriangulation(t *testing.T) {
 	}
 }
 
+func TestRemovePoint(t *testing.T) {
+	rand.Seed(0)
+	points := make([]*Point, 300)
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), 0)
+	}
+	tri, err := NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	// Remove points in a different order to that in which they were added.
+	rand.Shuffle(len(points), func(i, j int) { points[i], points[j] = points[j], points[i] })
+	for _, p := range points[:200] {
+		if err := tri.RemovePoint(p); err != nil {
+			t.Fatalf("error removing point: %v", err)
+		}
+		if len(p.Triangles) != 0 {
+			t.Errorf("expected removed point to have no triangles but it has %d", len(p.Triangles))
+		}
+	}
+	checkDelaunay(t, tri, points[200:])
+	// The triangle tree should still find triangles containing any point.
+	for i := 0; i < 100; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		leaf, err := tri.Locate(x, y)
+		if err != nil {
+			t.Fatalf("error locating point: %v", err)
+		}
+		if in, _ := leaf.Contains(NewPoint(x, y, 0)); !in || len(leaf.Children) != 0 {
+			t.Errorf("expected leaf triangle containing (%v,%v)", x, y)
+		}
+	}
+	if err := tri.RemovePoint(points[0]); !errors.Is(err, ErrPointNotFound) {
+		t.Errorf("expected error removing point twice")
+	}
+	if err := tri.RemovePoint(tri.Infinite); !errors.Is(err, ErrBoundingPoint) {
+		t.Errorf("expected error removing point at infinity")
+	}
+}
+
+func TestMovePoint(t *testing.T) {
+	rand.Seed(0)
+	points := make([]*Point, 300)
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), 0)
+	}
+	tri, err := NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	for step := 0; step < 10; step++ {
+		for i, p := range points {
+			// Move most points a little, staying inside the points around them, and some a long way.
+			x, y := p.X+0.001*(rand.Float64()-0.5), p.Y+0.001*(rand.Float64()-0.5)
+			if i%10 == 0 {
+				x, y = rand.Float64(), rand.Float64()
+			}
+			if err := tri.MovePoint(p, x, y); err != nil {
+				t.Fatalf("error moving point: %v", err)
+			}
+			if p.X != x || p.Y != y {
+				t.Errorf("expected point to be at (%v,%v) but it is at (%v,%v)", x, y, p.X, p.Y)
+			}
+		}
+		checkDelaunay(t, tri, points)
+	}
+	for i := 0; i < 100; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		leaf, err := tri.Locate(x, y)
+		if err != nil {
+			t.Fatalf("error locating point: %v", err)
+		}
+		if in, _ := leaf.Contains(NewPoint(x, y, 0)); !in || len(leaf.Children) != 0 {
+			t.Errorf("expected leaf triangle containing (%v,%v)", x, y)
+		}
+	}
+	// Points can be moved anywhere, including far outside the others.
+	if err := tri.MovePoint(points[0], 100, 100); err != nil {
+		t.Fatalf("error moving point outside hull: %v", err)
+	}
+	checkDelaunay(t, tri, points)
+}
+
+func TestUnboundedTriangulation(t *testing.T) {
+	rand.Seed(0)
+	points := []*Point{NewPoint(0, 0, 0), NewPoint(1, 0, 0), NewPoint(0, 1, 0)}
+	tri, err := NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	// Add points spiralling outwards, so that most of them are outside the hull of those before.
+	undos := []Undo{}
+	for i := 0; i < 300; i++ {
+		r, a := math.Pow(1.02, float64(i)), rand.Float64()*2*math.Pi
+		p := NewPoint(r*math.Cos(a), r*math.Sin(a), 0)
+		undo, err := tri.AddPoint(p)
+		if err != nil {
+			t.Fatalf("error adding point: %v", err)
+		}
+		points = append(points, p)
+		undos = append(undos, undo)
+	}
+	checkDelaunay(t, tri, points)
+	for i := 0; i < 100; i++ {
+		x, y := 1000*(rand.Float64()-0.5), 1000*(rand.Float64()-0.5)
+		leaf, err := tri.Locate(x, y)
+		if err != nil {
+			t.Fatalf("error locating point: %v", err)
+		}
+		if in, _ := leaf.Contains(NewPoint(x, y, 0)); !in || len(leaf.Children) != 0 {
+			t.Errorf("expected leaf triangle containing (%v,%v)", x, y)
+		}
+	}
+	for j := len(undos) - 1; j >= 100; j-- {
+		if err := undos[j](); err != nil {
+			t.Fatalf("error undoing point: %v", err)
+		}
+	}
+	points = points[:103]
+	checkDelaunay(t, tri, points)
+	// Removing points from the hull in any order leaves the rest delaunay.
+	rand.Shuffle(len(points), func(i, j int) { points[i], points[j] = points[j], points[i] })
+	for len(points) > 3 {
+		if err := tri.RemovePoint(points[0]); err != nil {
+			t.Fatalf("error removing point: %v", err)
+		}
+		points = points[1:]
+		checkDelaunay(t, tri, points)
+	}
+}
+
+func TestCollinearTriangulation(t *testing.T) {
+	points := []*Point{NewPoint(0, 0, 0), NewPoint(2, 2, 0), NewPoint(1, 1, 0), NewPoint(-1, -1, 0)}
+	tri, err := NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	// Collinear points have no triangles to be located in, but are still in the triangulation.
+	checkDelaunay(t, tri, points)
+	if _, err := tri.Locate(0.5, 0.5); !errors.Is(err, ErrDegenerate) {
+		t.Errorf("expected degenerate error locating point but got %v", err)
+	}
+	if _, err := tri.AddPoint(NewPoint(1, 1, 0)); !errors.Is(err, ErrDuplicatePoint) {
+		t.Errorf("expected duplicate point error but got %v", err)
+	}
+	// The first point off the line triangulates all of them, and undoing it takes them back.
+	p := NewPoint(0, 1, 0)
+	undo, err := tri.AddPoint(p)
+	if err != nil {
+		t.Fatalf("error adding point: %v", err)
+	}
+	checkDelaunay(t, tri, append(points, p))
+	if err := undo(); err != nil {
+		t.Fatalf("error undoing point: %v", err)
+	}
+	checkDelaunay(t, tri, points)
+	if len(p.Triangles) != 0 || tri.HasPoint(p) {
+		t.Errorf("expected undone point to be removed")
+	}
+	// Removing the only point off the line takes them back too, and moving a point off it brings them back.
+	tri.AddPoint(p)
+	if err := tri.RemovePoint(p); err != nil {
+		t.Fatalf("error removing point: %v", err)
+	}
+	checkDelaunay(t, tri, points)
+	if err := tri.RemovePoint(points[2]); err != nil {
+		t.Fatalf("error removing point: %v", err)
+	}
+	points = append(points[:2], points[3:]...)
+	checkDelaunay(t, tri, points)
+	if err := tri.MovePoint(points[0], 5, 0); err != nil {
+		t.Fatalf("error moving point: %v", err)
+	}
+	checkDelaunay(t, tri, points)
+}
+
+func TestWalkLocation(t *testing.T) {
+	for _, samples := range []int{0, -1, 10} {
+		rand.Seed(0)
+		points := make([]*Point, 1000)
+		for i := range points {
+			points[i] = NewPoint(rand.Float64(), rand.Float64(), 0)
+		}
+		tri, err := NewTriangulationWithOptions(points, Options{Location: LocateWalk, Samples: samples})
+		if err != nil {
+			t.Fatalf("error creating triangulation: %v", err)
+		}
+		if len(tri.Root.Children) != 0 {
+			t.Errorf("expected no triangle tree to be kept")
+		}
+		checkDelaunay(t, tri, points)
+		// Removed and moved points must not be walked from.
+		for _, p := range points[:300] {
+			if err := tri.RemovePoint(p); err != nil {
+				t.Fatalf("error removing point: %v", err)
+			}
+		}
+		points = points[300:]
+		for _, p := range points[:100] {
+			if err := tri.MovePoint(p, rand.Float64(), rand.Float64()); err != nil {
+				t.Fatalf("error moving point: %v", err)
+			}
+		}
+		p := NewPoint(2, 2, 0)
+		undo, err := tri.AddPoint(p)
+		if err != nil {
+			t.Fatalf("error adding point: %v", err)
+		}
+		if err := undo(); err != nil {
+			t.Fatalf("error undoing point: %v", err)
+		}
+		checkDelaunay(t, tri, points)
+		for i := 0; i < 100; i++ {
+			x, y := 3*rand.Float64()-1, 3*rand.Float64()-1
+			leaf, err := tri.Locate(x, y)
+			if err != nil {
+				t.Fatalf("error locating point: %v", err)
+			}
+			if in, _ := leaf.Contains(NewPoint(x, y, 0)); !in || !leaf.isLeaf() {
+				t.Errorf("samples %d: expected leaf triangle containing (%v,%v)", samples, x, y)
+			}
+		}
+	}
+	// The history of a triangulation can be dropped once it is built.
+	rand.Seed(0)
+	points := make([]*Point, 300)
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), 0)
+	}
+	tri, err := NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	p := NewPoint(0.5, 0.5, 0)
+	undo, err := tri.AddPoint(p)
+	if err != nil {
+		t.Fatalf("error adding point: %v", err)
+	}
+	tri.DiscardHistory()
+	if len(tri.Root.Children) != 0 {
+		t.Errorf("expected triangle tree to be dropped")
+	}
+	if err := undo(); err != nil {
+		t.Fatalf("error undoing point: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		p := NewPoint(rand.Float64(), rand.Float64(), 0)
+		if _, err := tri.AddPoint(p); err != nil {
+			t.Fatalf("error adding point: %v", err)
+		}
+		points = append(points, p)
+	}
+	checkDelaunay(t, tri, points)
+
+	// Constraints keep the triangulation from being delaunay, so walking towards a point can go round in circles if
+	// it always tries the edges of each triangle in the same order.
+	for seed := int64(0); seed < 40; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		points := make([]*Point, 400)
+		for i := range points {
+			points[i] = NewPoint(r.Float64(), r.Float64(), 0)
+		}
+		tri, err := NewTriangulationWithOptions(points, Options{Location: LocateWalk})
+		if err != nil {
+			t.Fatalf("error creating triangulation: %v", err)
+		}
+		for k := 0; k < 60; k++ {
+			p, q := points[r.Intn(len(points))], points[r.Intn(len(points))]
+			if p == q {
+				continue
+			}
+			if err := tri.AddConstraint(p, q); err != nil && !errors.Is(err, ErrConstraintCrossing) {
+				t.Fatalf("error adding constraint: %v", err)
+			}
+		}
+		for i := 0; i < 5000; i++ {
+			x, y := r.Float64(), r.Float64()
+			leaf, err := tri.Locate(x, y)
+			if err != nil {
+				t.Fatalf("seed %d: error locating point: %v", seed, err)
+			}
+			if in, _ := leaf.Contains(NewPoint(x, y, 0)); !in || !leaf.isLeaf() {
+				t.Errorf("seed %d: expected leaf triangle containing (%v,%v)", seed, x, y)
+			}
+		}
+	}
+}
+
+func TestInsertionOrder(t *testing.T) {
+	rand.Seed(0)
+	points := make([]*Point, 2000)
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), 0)
+	}
+	// Points sorted by one coordinate are the worst case for inserting in the order given.
+	sort.Slice(points, func(a, b int) bool { return points[a].Y < points[b].Y })
+	copies := make([]*Point, len(points))
+	for i, p := range points {
+		copies[i] = NewPoint(p.X, p.Y, 0)
+	}
+	brio, err := NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	checkDelaunay(t, brio, points)
+	given, err := NewTriangulationWithOptions(copies, Options{Order: OrderGiven})
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	checkDelaunay(t, given, copies)
+	// Random points have a unique delaunay triangulation, whatever order they are inserted in.
+	for i, p := range points {
+		if len(p.GetConnected()) != len(copies[i].GetConnected()) {
+			t.Errorf("expected point %d to be connected to the same points in both orders", i)
+		}
+	}
+	// The order is reproducible.
+	again := make([]*Point, len(points))
+	for i, p := range points {
+		again[i] = NewPoint(p.X, p.Y, 0)
+	}
+	if _, err := NewTriangulation(again); err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	for i, p := range points {
+		if len(p.Triangles) != len(again[i].Triangles) {
+			t.Errorf("expected point %d to have the same triangles each time", i)
+		}
+	}
+}
+
+func TestDivideAndConquer(t *testing.T) {
+	rand.Seed(0)
+	points := make([]*Point, 1000)
+	copies := make([]*Point, len(points))
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), 0)
+		copies[i] = NewPoint(points[i].X, points[i].Y, 0)
+	}
+	tri, err := NewTriangulationWithOptions(points, Options{Construction: ConstructDivideAndConquer})
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	checkDelaunay(t, tri, points)
+	if _, err := NewTriangulation(copies); err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	// Random points have a unique delaunay triangulation, however it is built.
+	for i, p := range points {
+		if len(p.GetConnected()) != len(copies[i].GetConnected()) {
+			t.Errorf("expected point %d to be connected to the same points as when built incrementally", i)
+		}
+	}
+	// The triangulation can be changed like any other.
+	for _, p := range points[:300] {
+		if err := tri.RemovePoint(p); err != nil {
+			t.Fatalf("error removing point: %v", err)
+		}
+	}
+	points = points[300:]
+	for _, p := range points[:100] {
+		if err := tri.MovePoint(p, rand.Float64(), rand.Float64()); err != nil {
+			t.Fatalf("error moving point: %v", err)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		p := NewPoint(3*rand.Float64()-1, 3*rand.Float64()-1, 0)
+		if _, err := tri.AddPoint(p); err != nil {
+			t.Fatalf("error adding point: %v", err)
+		}
+		points = append(points, p)
+	}
+	checkDelaunay(t, tri, points)
+	duplicates := []*Point{NewPoint(0, 0, 0), NewPoint(1, 0, 0), NewPoint(0, 1, 0), NewPoint(1, 0, 0)}
+	var dup *DuplicatePointError
+	if _, err := NewTriangulationWithOptions(duplicates, Options{Construction: ConstructDivideAndConquer}); !errors.As(err, &dup) || dup.Point != duplicates[3] || dup.Existing != duplicates[1] {
+		t.Errorf("expected duplicate point error but got %v", err)
+	}
+}
+
+func TestParallelDivideAndConquer(t *testing.T) {
+	rand.Seed(0)
+	random := make([]*Point, 20000)
+	for i := range random {
+		random[i] = NewPoint(rand.Float64(), rand.Float64(), 0)
+	}
+	// A grid has many ways to triangulate it, so shows up any difference in how the parts are merged.
+	grid := []*Point{}
+	for i := 0; i < 150; i++ {
+		for j := 0; j < 150; j++ {
+			grid = append(grid, NewPoint(float64(i), float64(j), 0))
+		}
+	}
+	for name, points := range map[string][]*Point{"random": random, "grid": grid} {
+		var expected [][3]int
+		for _, workers := range []int{1, 4} {
+			copies := make([]*Point, len(points))
+			index := map[*Point]int{}
+			for i, p := range points {
+				copies[i] = NewPoint(p.X, p.Y, 0)
+				index[copies[i]] = i
+			}
+			tri, err := NewTriangulationWithOptions(copies, Options{Construction: ConstructDivideAndConquer, Workers: workers})
+			if err != nil {
+				t.Fatalf("%s: error creating triangulation: %v", name, err)
+			}
+			checkDelaunay(t, tri, copies)
+			// The triangles of each point, in order, by the positions of their vertices.
+			triangles := [][3]int{}
+			for _, p := range copies {
+				for _, tr := range p.Triangles {
+					var vertices [3]int
+					for k, q := range tr.Points {
+						vertices[k] = -1
+						if !q.IsInfinite() {
+							vertices[k] = index[q]
+						}
+					}
+					triangles = append(triangles, vertices)
+				}
+			}
+			if expected == nil {
+				expected = triangles
+				continue
+			}
+			if len(triangles) != len(expected) {
+				t.Fatalf("%s: expected the same triangles with %d workers", name, workers)
+			}
+			for k := range triangles {
+				if triangles[k] != expected[k] {
+					t.Fatalf("%s: expected the same triangles with %d workers", name, workers)
+				}
+			}
+		}
+	}
+}
+
+func TestDegenerateTriangulation(t *testing.T) {
+	corpus := map[string]func() []*Point{
+		"grid": func() []*Point {
+			points := []*Point{}
+			for i := 0; i < 20; i++ {
+				for j := 0; j < 20; j++ {
+					points = append(points, NewPoint(float64(i), float64(j), 0))
+				}
+			}
+			return points
+		},
+		"fine grid": func() []*Point {
+			// Spacings that are not exactly representable, as in gridded survey data.
+			points := []*Point{}
+			for i := 0; i < 20; i++ {
+				for j := 0; j < 20; j++ {
+					points = append(points, NewPoint(1e6+float64(i)*0.1, 5e6+float64(j)*0.1, 0))
+				}
+			}
+			return points
+		},
+		"rotated grid": func() []*Point {
+			points := []*Point{}
+			s, c := math.Sincos(math.Pi / 7)
+			for i := 0; i < 20; i++ {
+				for j := 0; j < 20; j++ {
+					points = append(points, NewPoint(float64(i)*c-float64(j)*s, float64(i)*s+float64(j)*c, 0))
+				}
+			}
+			return points
+		},
+		"cocircular ring": func() []*Point {
+			points := make([]*Point, 64)
+			for i := range points {
+				points[i] = NewPoint(math.Sin(float64(i)*2*math.Pi/64), math.Cos(float64(i)*2*math.Pi/64), 0)
+			}
+			return points
+		},
+		"concentric rings": func() []*Point {
+			points := []*Point{NewPoint(0, 0, 0)}
+			for r := 1; r <= 5; r++ {
+				for i := 0; i < 24; i++ {
+					s, c := math.Sincos(float64(i) * 2 * math.Pi / 24)
+					points = append(points, NewPoint(float64(r)*s, float64(r)*c, 0))
+				}
+			}
+			return points
+		},
+		"line": func() []*Point {
+			points := make([]*Point, 50)
+			for i := range points {
+				points[i] = NewPoint(float64(i)*0.1, float64(i)*0.3, 0)
+			}
+			return points
+		},
+		"nearly collinear": func() []*Point {
+			points := make([]*Point, 50)
+			for i := range points {
+				x := 0.5 + float64(i)*1e-3
+				points[i] = NewPoint(x, 12*x+float64(i%3)*1e-15, 0)
+			}
+			return points
+		},
+	}
+	constructions := map[string]Construction{"incremental": ConstructIncremental, "divide and conquer": ConstructDivideAndConquer}
+	for name, generate := range corpus {
+		for cname, construction := range constructions {
+			t.Run(name+"/"+cname, func(t *testing.T) {
+				points := generate()
+				rand.Seed(0)
+				rand.Shuffle(len(points), func(i, j int) { points[i], points[j] = points[j], points[i] })
+				tri, err := NewTriangulationWithOptions(points, Options{Construction: construction})
+				if err != nil {
+					t.Fatalf("error creating triangulation: %v", err)
+				}
+				checkDelaunay(t, tri, points)
+				// Removing points must keep the degenerate remainder delaunay too.
+				for _, p := range points[:len(points)/2] {
+					if err := tri.RemovePoint(p); err != nil {
+						t.Fatalf("error removing point: %v", err)
+					}
+				}
+				checkDelaunay(t, tri, points[len(points)/2:])
+			})
+		}
+	}
+	single := []*Point{NewPoint(1, 2, 0)}
+	if tri, err := NewTriangulation(single); err != nil || !tri.HasPoint(single[0]) {
+		t.Errorf("error creating triangulation of a single point: %v", err)
+	} else {
+		checkDelaunay(t, tri, single)
+	}
+	if _, err := NewTriangulation([]*Point{NewPoint(0, 0, 0), NewPoint(math.NaN(), 1, 0)}); !errors.Is(err, ErrDegenerate) {
+		t.Errorf("expected degenerate error for point that is not finite but got %v", err)
+	}
+}
+
+func TestDuplicatePoints(t *testing.T) {
+	newPoints := func() []*Point {
+		rand.Seed(0)
+		points := make([]*Point, 100)
+		for i := range points {
+			points[i] = NewPoint(rand.Float64(), rand.Float64(), 1)
+		}
+		// Repeat some points, with different values.
+		for i := 0; i < 10; i++ {
+			points = append(points, NewPoint(points[i].X, points[i].Y, 2), NewPoint(points[i].X, points[i].Y, 6))
+		}
+		return points
+	}
+	points := newPoints()
+	_, err := NewTriangulation(points)
+	var dup *DuplicatePointError
+	if !errors.Is(err, ErrDuplicatePoint) || !errors.As(err, &dup) || dup.Point != points[100] || dup.Existing != points[0] {
+		t.Errorf("expected duplicate point error but got %v", err)
+	}
+	for _, c := range []struct {
+		policy   DuplicatePolicy
+		kept     func(points []*Point, i int) *Point
+		expected float64
+	}{
+		{DuplicateKeepFirst, func(points []*Point, i int) *Point { return points[i] }, 1},
+		{DuplicateKeepLast, func(points []*Point, i int) *Point { return points[101+2*i] }, 6},
+		{DuplicateAverage, func(points []*Point, i int) *Point { return points[i] }, 3},
+	} {
+		points := newPoints()
+		tri, err := NewTriangulationWithOptions(points, Options{Duplicates: c.policy})
+		if err != nil {
+			t.Fatalf("error creating triangulation: %v", err)
+		}
+		kept := append([]*Point{}, points[10:100]...)
+		for i := 0; i < 10; i++ {
+			p := c.kept(points, i)
+			if p.Value != c.expected {
+				t.Errorf("policy %v: expected kept point to have value %v but got %v", c.policy, c.expected, p.Value)
+			}
+			kept = append(kept, p)
+		}
+		checkDelaunay(t, tri, kept)
+		inserted := 0
+		for _, p := range points {
+			if len(p.Triangles) != 0 {
+				inserted++
+			}
+		}
+		if inserted != len(kept) {
+			t.Errorf("policy %v: expected %d points to be inserted but got %d", c.policy, len(kept), inserted)
+		}
+		// Points that are nil or not finite are rejected before looking for duplicates among them.
+		for _, bad := range []*Point{nil, NewPoint(math.NaN(), 0, 0)} {
+			_, err := NewTriangulationWithOptions(append(newPoints(), bad), Options{Duplicates: c.policy})
+			if !errors.Is(err, ErrDegenerate) {
+				t.Errorf("policy %v: expected degenerate error for bad point but got %v", c.policy, err)
+			}
+		}
+	}
+	// Points added or moved later onto an existing point are rejected, without losing the point being moved.
+	points = newPoints()[:100]
+	tri, err := NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	if _, err := tri.AddPoint(NewPoint(points[0].X, points[0].Y, 0)); !errors.As(err, &dup) {
+		t.Errorf("expected duplicate point error but got %v", err)
+	}
+	if err := tri.MovePoint(points[1], points[0].X, points[0].Y); !errors.As(err, &dup) {
+		t.Errorf("expected duplicate point error but got %v", err)
+	}
+	checkDelaunay(t, tri, points)
+}
+
+// checkDelaunay checks that the triangles attached to the given points, and the point at infinity, form a valid
+// delaunay triangulation.
+func TestConstraints(t *testing.T) {
+	rand.Seed(0)
+	points := make([]*Point, 300)
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), 0)
+	}
+	// A river winding across the points, made of constraints between points along it.
+	river := []*Point{NewPoint(0, 0.5, 0), NewPoint(0.25, 0.625, 0), NewPoint(0.55, 0.4, 0), NewPoint(0.8, 0.45, 0), NewPoint(1, 0.6, 0)}
+	points = append(points, river...)
+	tri, err := NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	for k := 1; k < len(river); k++ {
+		if err := tri.AddConstraint(river[k-1], river[k]); err != nil {
+			t.Fatalf("error adding constraint: %v", err)
+		}
+		if !river[k].IsConstrainedTo(river[k-1]) || !river[k-1].IsConstrainedTo(river[k]) {
+			t.Errorf("expected constraint %d to be added", k)
+		}
+	}
+	checkDelaunay(t, tri, points)
+	checkConstraints(t, points)
+	// A constraint crossing the river is an error, and changes nothing.
+	a, b := NewPoint(0.5, 0.1, 0), NewPoint(0.5, 0.9, 0)
+	tri.AddPoint(a)
+	tri.AddPoint(b)
+	points = append(points, a, b)
+	connected := len(a.GetConnected())
+	if err := tri.AddConstraint(a, b); !errors.Is(err, ErrConstraintCrossing) {
+		t.Errorf("expected error crossing constraints but got %v", err)
+	}
+	if len(a.GetConnected()) != connected || len(a.GetConstrained()) != 0 {
+		t.Errorf("expected failed constraint to change nothing")
+	}
+	if err := tri.AddConstraint(a, NewPoint(0.5, 0.2, 0)); !errors.Is(err, ErrPointNotFound) {
+		t.Errorf("expected error constraining point not in the triangulation but got %v", err)
+	}
+	checkDelaunay(t, tri, points)
+	checkConstraints(t, points)
+
+	// Points added on a constraint split it, and undoing them joins it back up.
+	mid := NewPoint(0.125, 0.5625, 0)
+	undo, err := tri.AddPoint(mid)
+	if err != nil {
+		t.Fatalf("error adding point: %v", err)
+	}
+	if !mid.IsConstrainedTo(river[0]) || !mid.IsConstrainedTo(river[1]) || river[0].IsConstrainedTo(river[1]) {
+		t.Errorf("expected point on constraint to split it")
+	}
+	if err := undo(); err != nil {
+		t.Fatalf("error undoing point: %v", err)
+	}
+	if !river[0].IsConstrainedTo(river[1]) || len(mid.GetConstrained()) != 0 {
+		t.Errorf("expected undo to join constraint back up")
+	}
+	// Removing a point splitting a straight constraint joins it back up, and removing its end removes it.
+	tri.AddPoint(mid)
+	if err := tri.RemovePoint(mid); err != nil {
+		t.Fatalf("error removing point: %v", err)
+	}
+	if !river[0].IsConstrainedTo(river[1]) || len(mid.GetConstrained()) != 0 {
+		t.Errorf("expected removing point to join constraint back up")
+	}
+	if err := tri.RemovePoint(river[4]); err != nil {
+		t.Fatalf("error removing point: %v", err)
+	}
+	if river[3].IsConstrainedTo(river[4]) || len(river[4].GetConstrained()) != 0 {
+		t.Errorf("expected removing point to remove its constraints")
+	}
+	points = append(points[:len(points)-3], a, b)
+	checkDelaunay(t, tri, points)
+	checkConstraints(t, points)
+
+	// Constraints move with their points, and are never flipped away as other points move or are removed.
+	for step := 0; step < 5; step++ {
+		for i, p := range points {
+			x, y := p.X+0.002*(rand.Float64()-0.5), p.Y+0.002*(rand.Float64()-0.5)
+			if i%20 == 0 {
+				x, y = rand.Float64(), rand.Float64()
+			}
+			if err := tri.MovePoint(p, x, y); err != nil && !errors.Is(err, ErrConstraintCrossing) {
+				t.Fatalf("error moving point: %v", err)
+			}
+		}
+		checkDelaunay(t, tri, points)
+		checkConstraints(t, points)
+	}
+	rand.Shuffle(len(points), func(i, j int) { points[i], points[j] = points[j], points[i] })
+	for len(points) > 100 {
+		if err := tri.RemovePoint(points[0]); err != nil {
+			t.Fatalf("error removing point: %v", err)
+		}
+		points = points[1:]
+	}
+	checkDelaunay(t, tri, points)
+	checkConstraints(t, points)
+
+	// A constraint through other points is split at each of them.
+	grid := make([]*Point, 25)
+	for i := range grid {
+		grid[i] = NewPoint(float64(i%5), float64(i/5), 0)
+	}
+	tri, err = NewTriangulation(grid)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	if err := tri.AddConstraint(grid[4], grid[20]); err != nil {
+		t.Fatalf("error adding constraint: %v", err)
+	}
+	for k := 1; k < 5; k++ {
+		if !grid[4*k].IsConstrainedTo(grid[4*k+4]) {
+			t.Errorf("expected constraint between (%v,%v) and (%v,%v)", grid[4*k].X, grid[4*k].Y, grid[4*k+4].X, grid[4*k+4].Y)
+		}
+	}
+	if err := tri.AddConstraint(grid[0], grid[9]); !errors.Is(err, ErrConstraintCrossing) {
+		t.Errorf("expected error crossing constraint through points but got %v", err)
+	}
+	// Constraints may meet at a point.
+	if err := tri.AddConstraint(grid[0], grid[24]); err != nil {
+		t.Errorf("error adding constraint meeting another: %v", err)
+	}
+	checkDelaunay(t, tri, grid)
+	checkConstraints(t, grid)
+}
+
+func TestRefine(t *testing.T) {
+	rand.Seed(0)
+	points := make([]*Point, 100)
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), 0)
+	}
+	// A square hull, and a constraint across the middle of it.
+	a, b := NewPoint(0.2, 0.3, 0), NewPoint(0.7, 0.75, 0)
+	points = append(points, NewPoint(0, 0, 0), NewPoint(1, 0, 0), NewPoint(1, 1, 0), NewPoint(0, 1, 0), a, b)
+	tri, err := NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	if err := tri.AddConstraint(a, b); err != nil {
+		t.Fatalf("error adding constraint: %v", err)
+	}
+	opts := RefineOptions{
+		MinAngle: 25,
+		MaxArea:  0.002,
+		Values: func(x, y float64) ([]float64, error) {
+			return []float64{x + y}, nil
+		},
+	}
+	added, err := tri.Refine(opts)
+	if err != nil {
+		t.Fatalf("error refining triangulation: %v", err)
+	}
+	for _, p := range added {
+		if p.Value != p.X+p.Y {
+			t.Errorf("expected point added at (%v,%v) to have value %v but got %v", p.X, p.Y, p.X+p.Y, p.Value)
+		}
+	}
+	all := append(points, added...)
+	checkDelaunay(t, tri, all)
+	checkConstraints(t, all)
+	triangles := map[*Triangle]bool{}
+	for _, p := range all {
+		if p.X < 0 || p.X > 1 || p.Y < 0 || p.Y > 1 {
+			t.Errorf("expected point (%v,%v) to be inside the hull", p.X, p.Y)
+		}
+		for _, s := range p.Triangles {
+			if !s.IsGhost() {
+				triangles[s] = true
+			}
+		}
+	}
+	for s := range triangles {
+		p1, p2, p3 := s.getPoints()
+		if area := math.Abs(geom.Det3s(p1.X, p1.Y, p2.X, p2.Y, p3.X, p3.Y)) / 2; area > opts.MaxArea {
+			t.Errorf("expected triangle to have area at most %v but it has %v", opts.MaxArea, area)
+		}
+		for k := range s.Points {
+			u, v, w := s.Points[k], s.Points[(k+1)%3], s.Points[(k+2)%3]
+			angle := math.Atan2(math.Abs(geom.Det2(v.X-u.X, v.Y-u.Y, w.X-u.X, w.Y-u.Y)), (v.X-u.X)*(w.X-u.X)+(v.Y-u.Y)*(w.Y-u.Y))
+			if angle*180/math.Pi < opts.MinAngle-1e-9 {
+				t.Errorf("expected triangle to have angles of at least %v degrees but it has %v", opts.MinAngle, angle*180/math.Pi)
+			}
+		}
+	}
+	// The constraint is split into pieces that still join its ends.
+	for prev, p := (*Point)(nil), a; p != b; {
+		next := p.GetConstrained()
+		if len(next) == 0 || len(next) > 2 {
+			t.Fatalf("expected constraint to run from (%v,%v) to (%v,%v)", a.X, a.Y, b.X, b.Y)
+		}
+		if next[0] == prev {
+			next = next[1:]
+		}
+		prev, p = p, next[0]
+	}
+
+	// Refinement stops at the limit of points.
+	points = make([]*Point, 20)
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), 0)
+	}
+	points = append(points, NewPoint(0, 0, 0), NewPoint(1, 0, 0), NewPoint(1, 1, 0), NewPoint(0, 1, 0))
+	tri, err = NewTriangulationWithOptions(points, Options{Location: LocateWalk})
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	added, err = tri.Refine(RefineOptions{MaxArea: 0.0001, MaxPoints: 10})
+	if !errors.Is(err, ErrRefinementLimit) || len(added) != 10 {
+		t.Errorf("expected error reaching limit after adding 10 points but got %v after %d", err, len(added))
+	}
+	checkDelaunay(t, tri, append(points, added...))
+	// Triangles that could not be split are reported if they still do not meet the bounds at the end, once each.
+	points = []*Point{NewPoint(0, 0, 0), NewPoint(1, 0, 0), NewPoint(1, 1, 0), NewPoint(0, 1, 0), NewPoint(0.5, 0.01, 0)}
+	tri, err = NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	r := refiner{t: tri, opts: RefineOptions{MaxArea: 0.3}, maxRatio: math.Inf(1), limit: 10, channels: 1}
+	bad := 0
+	for _, s := range points[4].Triangles {
+		if r.isBad(s) {
+			bad++
+		}
+		r.unsplit = append(r.unsplit, s, s)
+	}
+	if err := r.run(); !errors.Is(err, ErrRefinementIncomplete) || err.Error() != fmt.Sprintf("%v: %d triangles", ErrRefinementIncomplete, bad) {
+		t.Errorf("expected error for %d triangles left unsplit but got %v", bad, err)
+	}
+	if _, err := tri.Refine(r.opts); err != nil {
+		t.Errorf("error refining triangulation: %v", err)
+	}
+	if _, err := tri.Refine(RefineOptions{MinAngle: 45}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected error refining to an angle too large but got %v", err)
+	}
+}
+
+// checkConstraints checks that every constraint of the points is an edge, and that no edge crosses one.
+func checkConstraints(t *testing.T, points []*Point) {
+	t.Helper()
+	for _, p := range points {
+		for _, q := range p.GetConstrained() {
+			if !q.IsConstrainedTo(p) {
+				t.Errorf("expected constraints to go both ways")
+			}
+			if getTriangleWithEdge(p, q) == nil {
+				t.Errorf("expected constraint (%v,%v)-(%v,%v) to be an edge", p.X, p.Y, q.X, q.Y)
+			}
+			for _, r := range points {
+				for _, s := range r.GetConnected() {
+					if isCrossing(p, q, r, s) {
+						t.Errorf("expected no edge to cross a constraint")
+					}
+				}
+			}
+		}
+	}
+}
+
+func checkDelaunay(t *testing.T, tri *Triangulation, points []*Point) {
+	t.Helper()
+	triangles := map[*Triangle]bool{}
+	for _, p := range append(points, tri.Infinite) {
+		if p != tri.Infinite && !tri.HasPoint(p) {
+			t.Errorf("expected point (%v,%v) to be in the triangulation", p.X, p.Y)
+		}
+		for _, tr := range p.Triangles {
+			triangles[tr] = true
+		}
+	}
+	// Counting the ghost triangles, a triangulation of n points has 2n-2 triangles, unless they are all collinear.
+	expected := 2*len(points) - 2
+	if tri.isFlat() {
+		expected = 0
+	}
+	if len(triangles) != expected {
+		t.Errorf("expected %d triangles but got %d", expected, len(triangles))
+	}
+	if got := len(tri.GetPoints()); got != len(points) {
+		t.Errorf("expected %d points but got %d", len(points), got)
+	}
+	for tr := range triangles {
+		if len(tr.Children) != 0 {
+			t.Errorf("expected only leaf triangles to be attached to points")
+		}
+		for _, p := range tr.Points {
+			adj := tr.GetTriangleOpposite(p)
+			if adj != nil && !tr.IsDelaunayWith(adj) {
+				t.Errorf("expected triangles to be delaunay")
+			}
+		}
+	}
+}
+
 var result *Triangulation
 
 func benchmarkTriangulation(n int, b *testing.B) {
+	benchmarkTriangulationWithOptions(n, Options{}, b)
+}
+
+func benchmarkTriangulationWithOptions(n int, opts Options, b *testing.B) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		b.StopTimer()
 		points := make([]*Point, n)
@@ -33,7 +947,7 @@ func benchmarkTriangulation(n int, b *testing.B) {
 			points[j] = NewPoint(rand.Float64(), rand.Float64(), 0)
 		}
 		b.StartTimer()
-		result, _ = NewTriangulation(points)
+		result, _ = NewTriangulationWithOptions(points, opts)
 	}
 }
 
@@ -44,3 +958,45 @@ func BenchmarkTriangulation10000(b *testing.B)   { benchmarkTriangulation(10000,
 func BenchmarkTriangulation50000(b *testing.B)   { benchmarkTriangulation(50000, b) }
 func BenchmarkTriangulation100000(b *testing.B)  { benchmarkTriangulation(100000, b) }
 func BenchmarkTriangulation1000000(b *testing.B) { benchmarkTriangulation(1000000, b) }
+
+func benchmarkSortedTriangulation(n int, opts Options, b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		points := make([]*Point, n)
+		for j := 0; j < n; j++ {
+			points[j] = NewPoint(rand.Float64(), rand.Float64(), 0)
+		}
+		sort.Slice(points, func(a, b int) bool { return points[a].Y < points[b].Y })
+		b.StartTimer()
+		result, _ = NewTriangulationWithOptions(points, opts)
+	}
+}
+
+func BenchmarkSortedTriangulation10000(b *testing.B) {
+	benchmarkSortedTriangulation(10000, Options{}, b)
+}
+func BenchmarkSortedTriangulationGivenOrder10000(b *testing.B) {
+	benchmarkSortedTriangulation(10000, Options{Order: OrderGiven}, b)
+}
+
+func BenchmarkDivideAndConquerTriangulation100000(b *testing.B) {
+	benchmarkTriangulationWithOptions(100000, Options{Construction: ConstructDivideAndConquer}, b)
+}
+func BenchmarkDivideAndConquerTriangulation1000000(b *testing.B) {
+	benchmarkTriangulationWithOptions(1000000, Options{Construction: ConstructDivideAndConquer}, b)
+}
+
+func BenchmarkSequentialDivideAndConquerTriangulation1000000(b *testing.B) {
+	benchmarkTriangulationWithOptions(1000000, Options{Construction: ConstructDivideAndConquer, Workers: 1}, b)
+}
+
+func BenchmarkWalkTriangulation1000(b *testing.B) {
+	benchmarkTriangulationWithOptions(1000, Options{Location: LocateWalk}, b)
+}
+func BenchmarkWalkTriangulation100000(b *testing.B) {
+	benchmarkTriangulationWithOptions(100000, Options{Location: LocateWalk}, b)
+}
+func BenchmarkWalkTriangulation1000000(b *testing.B) {
+	benchmarkTriangulationWithOptions(1000000, Options{Location: LocateWalk}, b)
+}
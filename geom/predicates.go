@@ -0,0 +1,201 @@
+package geom
+
+import "math"
+
+// The predicates in this file give exactly correct signs, however close the points are to being collinear or
+// cocircular. Each one first evaluates its determinant in ordinary floating point arithmetic, along with a bound on
+// the rounding error. Only if the result is too close to zero for its sign to be certain is the determinant
+// evaluated again exactly, using expansions: sums of non-overlapping floats, held in order of increasing magnitude.
+// Shewchuk, "Adaptive Precision Floating-Point Arithmetic and Fast Robust Geometric Predicates" (1997).
+// https://www.cs.cmu.edu/~quake/robust.html
+
+// epsilon is half the distance between 1 and the next largest float64, the largest relative rounding error of a
+// single floating point operation.
+const epsilon = 1.0 / (1 << 53)
+
+// Bounds on the relative error of the floating point evaluations of the determinants.
+const (
+	ccwErrBound = (3 + 16*epsilon) * epsilon
+	iccErrBound = (10 + 96*epsilon) * epsilon
+)
+
+// Orient2d returns a positive value if the points a, b and c are in anticlockwise order, a negative value if they
+// are in clockwise order and zero if they are collinear.
+// The sign is always correct. The value approximates twice the signed area of the triangle abc.
+func Orient2d(ax, ay, bx, by, cx, cy float64) float64 {
+	detLeft := (ax - cx) * (by - cy)
+	detRight := (ay - cy) * (bx - cx)
+	det := detLeft - detRight
+	var detSum float64
+	switch {
+	case detLeft > 0:
+		if detRight <= 0 {
+			return det
+		}
+		detSum = detLeft + detRight
+	case detLeft < 0:
+		if detRight >= 0 {
+			return det
+		}
+		detSum = -detLeft - detRight
+	default:
+		return det
+	}
+	if bound := ccwErrBound * detSum; det >= bound || -det >= bound {
+		return det
+	}
+	return orient2dExact(ax, ay, bx, by, cx, cy)
+}
+
+// orient2dExact evaluates the determinant of Orient2d exactly.
+func orient2dExact(ax, ay, bx, by, cx, cy float64) float64 {
+	acx, acy := twoDiff(ax, cx), twoDiff(ay, cy)
+	bcx, bcy := twoDiff(bx, cx), twoDiff(by, cy)
+	det := expansionDiff(expansionProduct(acx, bcy), expansionProduct(acy, bcx))
+	return det[len(det)-1]
+}
+
+// Incircle returns a positive value if the point d lies inside the circle through the points a, b and c, a negative
+// value if it lies outside and zero if it lies on the circle, when a, b and c are in anticlockwise order.
+// If they are in clockwise order, the sign is reversed.
+// The sign is always correct.
+func Incircle(ax, ay, bx, by, cx, cy, dx, dy float64) float64 {
+	adx, ady := ax-dx, ay-dy
+	bdx, bdy := bx-dx, by-dy
+	cdx, cdy := cx-dx, cy-dy
+
+	bdxcdy, cdxbdy := bdx*cdy, cdx*bdy
+	aLift := adx*adx + ady*ady
+	cdxady, adxcdy := cdx*ady, adx*cdy
+	bLift := bdx*bdx + bdy*bdy
+	adxbdy, bdxady := adx*bdy, bdx*ady
+	cLift := cdx*cdx + cdy*cdy
+
+	det := aLift*(bdxcdy-cdxbdy) + bLift*(cdxady-adxcdy) + cLift*(adxbdy-bdxady)
+	permanent := (math.Abs(bdxcdy)+math.Abs(cdxbdy))*aLift +
+		(math.Abs(cdxady)+math.Abs(adxcdy))*bLift +
+		(math.Abs(adxbdy)+math.Abs(bdxady))*cLift
+	if bound := iccErrBound * permanent; det > bound || -det > bound {
+		return det
+	}
+	return incircleExact(ax, ay, bx, by, cx, cy, dx, dy)
+}
+
+// incircleExact evaluates the determinant of Incircle exactly.
+func incircleExact(ax, ay, bx, by, cx, cy, dx, dy float64) float64 {
+	adx, ady := twoDiff(ax, dx), twoDiff(ay, dy)
+	bdx, bdy := twoDiff(bx, dx), twoDiff(by, dy)
+	cdx, cdy := twoDiff(cx, dx), twoDiff(cy, dy)
+	lift := func(x, y []float64) []float64 {
+		return expansionSum(expansionProduct(x, x), expansionProduct(y, y))
+	}
+	cross := func(x1, y1, x2, y2 []float64) []float64 {
+		return expansionDiff(expansionProduct(x1, y2), expansionProduct(x2, y1))
+	}
+	det := expansionSum(
+		expansionProduct(lift(adx, ady), cross(bdx, bdy, cdx, cdy)),
+		expansionSum(
+			expansionProduct(lift(bdx, bdy), cross(cdx, cdy, adx, ady)),
+			expansionProduct(lift(cdx, cdy), cross(adx, ady, bdx, bdy)),
+		),
+	)
+	return det[len(det)-1]
+}
+
+// twoSum returns a+b rounded to a float, and the rounding error, so that together they are exactly a+b.
+func twoSum(a, b float64) (x, y float64) {
+	x = a + b
+	bv := x - a
+	av := x - bv
+	return x, (a - av) + (b - bv)
+}
+
+// fastTwoSum is twoSum for when |a| >= |b|.
+func fastTwoSum(a, b float64) (x, y float64) {
+	x = a + b
+	return x, b - (x - a)
+}
+
+// twoProduct returns a*b rounded to a float, and the rounding error, so that together they are exactly a*b.
+func twoProduct(a, b float64) (x, y float64) {
+	x = a * b
+	return x, math.FMA(a, b, -x)
+}
+
+// twoDiff returns the expansion that is exactly a-b.
+func twoDiff(a, b float64) []float64 {
+	x, y := twoSum(a, -b)
+	if y == 0 {
+		return []float64{x}
+	}
+	return []float64{y, x}
+}
+
+// growExpansion returns the expansion that is exactly e+b, leaving out any zero components.
+func growExpansion(e []float64, b float64) []float64 {
+	h := make([]float64, 0, len(e)+1)
+	q := b
+	for _, c := range e {
+		var err float64
+		q, err = twoSum(q, c)
+		if err != 0 {
+			h = append(h, err)
+		}
+	}
+	if q != 0 || len(h) == 0 {
+		h = append(h, q)
+	}
+	return h
+}
+
+// expansionSum returns the expansion that is exactly e+f.
+func expansionSum(e, f []float64) []float64 {
+	h := e
+	for _, c := range f {
+		h = growExpansion(h, c)
+	}
+	return h
+}
+
+// expansionDiff returns the expansion that is exactly e-f.
+func expansionDiff(e, f []float64) []float64 {
+	h := e
+	for _, c := range f {
+		h = growExpansion(h, -c)
+	}
+	return h
+}
+
+// scaleExpansion returns the expansion that is exactly e*b, leaving out any zero components.
+func scaleExpansion(e []float64, b float64) []float64 {
+	h := make([]float64, 0, 2*len(e))
+	q, err := twoProduct(e[0], b)
+	if err != 0 {
+		h = append(h, err)
+	}
+	for _, c := range e[1:] {
+		p1, p0 := twoProduct(c, b)
+		var sum float64
+		sum, err = twoSum(q, p0)
+		if err != 0 {
+			h = append(h, err)
+		}
+		q, err = fastTwoSum(p1, sum)
+		if err != 0 {
+			h = append(h, err)
+		}
+	}
+	if q != 0 || len(h) == 0 {
+		h = append(h, q)
+	}
+	return h
+}
+
+// expansionProduct returns the expansion that is exactly e*f.
+func expansionProduct(e, f []float64) []float64 {
+	h := scaleExpansion(e, f[0])
+	for _, c := range f[1:] {
+		h = expansionSum(h, scaleExpansion(e, c))
+	}
+	return h
+}
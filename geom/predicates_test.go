@@ -0,0 +1,63 @@
+package geom
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestPredicates(t *testing.T) {
+	rand.Seed(0)
+	for i := 0; i < 10000; i++ {
+		// Points very nearly on the line y = 0.3x, and on the circle through (0,0), (1,0) and (0,1), where plain
+		// floating point determinants often get the sign wrong.
+		ax, bx := rand.Float64(), rand.Float64()
+		cx := 0.5 + float64(rand.Intn(1000))*1e-12
+		ay, by, cy := 0.3*ax, 0.3*bx, 0.3*cx+float64(rand.Intn(3)-1)*1e-17
+		if got, want := sign(Orient2d(ax, ay, bx, by, cx, cy)), orient2dBig(ax, ay, bx, by, cx, cy); got != want {
+			t.Fatalf("Orient2d(%v, %v, %v, %v, %v, %v) has sign %d but expected %d", ax, ay, bx, by, cx, cy, got, want)
+		}
+		dx := 0.5 + 0.5*rand.Float64()
+		dy := 0.5 + math.Sqrt(0.5-(dx-0.5)*(dx-0.5)) + float64(rand.Intn(3)-1)*1e-16
+		if got, want := sign(Incircle(0, 0, 1, 0, 0, 1, dx, dy)), incircleBig(0, 0, 1, 0, 0, 1, dx, dy); got != want {
+			t.Fatalf("Incircle(0, 0, 1, 0, 0, 1, %v, %v) has sign %d but expected %d", dx, dy, got, want)
+		}
+	}
+}
+
+func sign(x float64) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+func orient2dBig(ax, ay, bx, by, cx, cy float64) int {
+	acx, acy := bigDiff(ax, cx), bigDiff(ay, cy)
+	bcx, bcy := bigDiff(bx, cx), bigDiff(by, cy)
+	return new(big.Rat).Sub(new(big.Rat).Mul(acx, bcy), new(big.Rat).Mul(acy, bcx)).Sign()
+}
+
+func incircleBig(ax, ay, bx, by, cx, cy, dx, dy float64) int {
+	adx, ady := bigDiff(ax, dx), bigDiff(ay, dy)
+	bdx, bdy := bigDiff(bx, dx), bigDiff(by, dy)
+	cdx, cdy := bigDiff(cx, dx), bigDiff(cy, dy)
+	lift := func(x, y *big.Rat) *big.Rat {
+		return new(big.Rat).Add(new(big.Rat).Mul(x, x), new(big.Rat).Mul(y, y))
+	}
+	cross := func(x1, y1, x2, y2 *big.Rat) *big.Rat {
+		return new(big.Rat).Sub(new(big.Rat).Mul(x1, y2), new(big.Rat).Mul(x2, y1))
+	}
+	det := new(big.Rat).Mul(lift(adx, ady), cross(bdx, bdy, cdx, cdy))
+	det.Add(det, new(big.Rat).Mul(lift(bdx, bdy), cross(cdx, cdy, adx, ady)))
+	det.Add(det, new(big.Rat).Mul(lift(cdx, cdy), cross(adx, ady, bdx, bdy)))
+	return det.Sign()
+}
+
+func bigDiff(a, b float64) *big.Rat {
+	return new(big.Rat).Sub(new(big.Rat).SetFloat64(a), new(big.Rat).SetFloat64(b))
+}
@@ -12,10 +12,26 @@ func GetArea(p1x, p1y, p2x, p2y, p3x, p3y float64) float64 {
 }
 
 // IsClockwise returns whether the given points are defined in clockwise order.
+// It is exact, so collinear points are never clockwise.
 func IsClockwise(p1x, p1y, p2x, p2y, p3x, p3y float64) bool {
-	return Det3s(
+	return Orient2d(
 		p1x, p1y,
 		p2x, p2y,
 		p3x, p3y,
 	) < 0
 }
+
+// GetCircumcenter returns the coordinates of the center of the circle passing through the three points.
+// It works relative to the first point, so that coordinates far from the origin, such as projected map coordinates,
+// keep their precision.
+// Adapted from https://gist.github.com/mutoo/5617691.
+func GetCircumcenter(p1x, p1y, p2x, p2y, p3x, p3y float64) (x, y float64) {
+	bx, by := p2x-p1x, p2y-p1y
+	cx, cy := p3x-p1x, p3y-p1y
+	mb := bx*bx + by*by
+	mc := cx*cx + cy*cy
+	f := 1 / (2 * Det2(bx, by, cx, cy))
+	x = p1x + f*Det2(mb, by, mc, cy)
+	y = p1y - f*Det2(mb, bx, mc, cx)
+	return
+}
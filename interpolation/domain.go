@@ -0,0 +1,271 @@
+package interpolation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/edwardbrowncross/naturalneighbour/geom"
+	"github.com/edwardbrowncross/naturalneighbour/voronoi"
+)
+
+// Domain is the region an Interpolator gives values in: inside its boundary and outside all of its holes.
+type Domain struct {
+	// Boundary is the outer boundary of the domain, with its vertices in either winding order. If it is empty, the
+	// domain reaches out in every direction.
+	Boundary []voronoi.Vertex
+	// Holes are polygons inside the domain that are not part of it, such as lakes or buildings. They should not
+	// overlap each other.
+	Holes [][]voronoi.Vertex
+}
+
+// NoData selects what happens when interpolating outside an Interpolator's Domain.
+type NoData int
+
+const (
+	// NoDataNaN returns NaN.
+	NoDataNaN NoData = iota
+	// NoDataError returns an error.
+	NoDataError
+	// NoDataFill returns Options.FillValue.
+	NoDataFill
+)
+
+// Contains returns whether the given coordinates are in the domain. Coordinates on its boundary, or on the edge of
+// one of its holes, are in it.
+func (d *Domain) Contains(x, y float64) bool {
+	if len(d.Boundary) != 0 && !isInPolygon(d.Boundary, x, y, true) {
+		return false
+	}
+	for _, h := range d.Holes {
+		if isInPolygon(h, x, y, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// validate returns an error if any of the polygons of the domain has fewer than three vertices.
+func (d *Domain) validate() error {
+	if len(d.Boundary) != 0 && len(d.Boundary) < 3 {
+		return fmt.Errorf("%w: domain boundary has %d vertices", ErrInvalidArgument, len(d.Boundary))
+	}
+	for j, h := range d.Holes {
+		if len(h) < 3 {
+			return fmt.Errorf("%w: domain hole %d has %d vertices", ErrInvalidArgument, j, len(h))
+		}
+	}
+	return nil
+}
+
+// isInPolygon returns whether the given coordinates lie inside the polygon, by the even-odd rule, or on its edge if
+// onEdge is set.
+// https://wrf.ecse.rpi.edu/Research/Short_Notes/pnpoly.html
+func isInPolygon(polygon []voronoi.Vertex, x, y float64, onEdge bool) bool {
+	inside := false
+	for j, a := range polygon {
+		b := polygon[(j+1)%len(polygon)]
+		if geom.Orient2d(a.X, a.Y, b.X, b.Y, x, y) == 0 &&
+			math.Min(a.X, b.X) <= x && x <= math.Max(a.X, b.X) && math.Min(a.Y, b.Y) <= y && y <= math.Max(a.Y, b.Y) {
+			return onEdge
+		}
+		if (a.Y > y) != (b.Y > y) && x < a.X+(y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// clipToDomain sets the fraction of each neighbour's weight that lies outside the domain, so that only the parts of
+// the query location's voronoi cell inside the domain count: the area taken from each neighbour in Sibson and
+// SibsonC1 modes, or the length of the voronoi edge shared with it in Laplace mode.
+func clipToDomain(neighbours []neighbour, d *Domain, mode Mode, x, y float64) {
+	if mode == Laplace {
+		for j, n := range neighbours {
+			f0, f1 := n.facet[0], n.facet[1]
+			if length := math.Hypot(f1.X-f0.X, f1.Y-f0.Y); length > 0 {
+				neighbours[j].outside = 1 - d.segmentLength(f0, f1)/length
+			}
+		}
+		return
+	}
+	// Every vertex of the query location's cell is an end of a neighbour's facet, so the box around those holds it.
+	minX, minY, maxX, maxY := x, y, x, y
+	for _, n := range neighbours {
+		for _, f := range n.facet {
+			minX, maxX = math.Min(minX, f.X), math.Max(maxX, f.X)
+			minY, maxY = math.Min(minY, f.Y), math.Max(maxY, f.Y)
+		}
+	}
+	if !d.crosses(minX, minY, maxX, maxY) {
+		// The cell is either wholly inside the domain or wholly outside it, and the query location is inside it.
+		return
+	}
+	pad := math.Max(maxX-minX, maxY-minY) + 1
+	cell := []voronoi.Vertex{
+		{X: minX - pad, Y: minY - pad}, {X: maxX + pad, Y: minY - pad},
+		{X: maxX + pad, Y: maxY + pad}, {X: minX - pad, Y: maxY + pad},
+	}
+	for _, m := range neighbours {
+		cell = bisector(x, y, m.x, m.y).clip(cell)
+	}
+	// The area taken from each neighbour is the part of the cell nearer to it than to any other neighbour.
+	for j, n := range neighbours {
+		taken := cell
+		for k, m := range neighbours {
+			if k != j {
+				taken = bisector(n.x, n.y, m.x, m.y).clip(taken)
+			}
+		}
+		if area := voronoi.PolygonArea(taken); area > 0 {
+			neighbours[j].outside = math.Max(0, math.Min(1, 1-d.convexArea(taken)/area))
+		}
+	}
+}
+
+// crosses returns whether any edge of the domain's polygons passes through the given box.
+func (d *Domain) crosses(minX, minY, maxX, maxY float64) bool {
+	for _, polygon := range append([][]voronoi.Vertex{d.Boundary}, d.Holes...) {
+		for j, a := range polygon {
+			b := polygon[(j+1)%len(polygon)]
+			// Clip the edge to the box, one axis at a time.
+			t0, t1 := 0.0, 1.0
+			sides := [][3]float64{{a.X, b.X - a.X, minX}, {-a.X, a.X - b.X, -maxX}, {a.Y, b.Y - a.Y, minY}, {-a.Y, a.Y - b.Y, -maxY}}
+			for _, s := range sides {
+				// The part of the edge with s[0] + t*s[1] >= s[2] is on the inside of this side of the box.
+				if s[1] == 0 {
+					if s[0] < s[2] {
+						t0, t1 = 1, 0
+					}
+					continue
+				}
+				t := (s[2] - s[0]) / s[1]
+				if s[1] > 0 {
+					t0 = math.Max(t0, t)
+				} else {
+					t1 = math.Min(t1, t)
+				}
+			}
+			if t0 <= t1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// convexArea returns the area of the part of the given convex polygon that is in the domain.
+func (d *Domain) convexArea(polygon []voronoi.Vertex) float64 {
+	window := convexWindow(polygon)
+	clipped := func(subject []voronoi.Vertex) float64 {
+		for _, h := range window {
+			subject = h.clip(subject)
+		}
+		return math.Abs(voronoi.SignedArea(subject))
+	}
+	area := math.Abs(voronoi.SignedArea(polygon))
+	if len(d.Boundary) != 0 {
+		area = clipped(d.Boundary)
+	}
+	for _, h := range d.Holes {
+		area -= clipped(h)
+	}
+	return area
+}
+
+// segmentLength returns the length of the part of the segment from a to b that is in the domain.
+func (d *Domain) segmentLength(a, b voronoi.Vertex) float64 {
+	// Split the segment wherever it crosses an edge of the domain, and test the middle of each piece.
+	ts := []float64{0, 1}
+	for _, polygon := range append([][]voronoi.Vertex{d.Boundary}, d.Holes...) {
+		for j, c := range polygon {
+			e := polygon[(j+1)%len(polygon)]
+			den := geom.Det2(b.X-a.X, b.Y-a.Y, e.X-c.X, e.Y-c.Y)
+			if den == 0 {
+				continue
+			}
+			t := geom.Det2(c.X-a.X, c.Y-a.Y, e.X-c.X, e.Y-c.Y) / den
+			u := geom.Det2(c.X-a.X, c.Y-a.Y, b.X-a.X, b.Y-a.Y) / den
+			if t > 0 && t < 1 && u >= 0 && u <= 1 {
+				ts = append(ts, t)
+			}
+		}
+	}
+	sort.Float64s(ts)
+	length := 0.0
+	for j := 1; j < len(ts); j++ {
+		mid := (ts[j-1] + ts[j]) / 2
+		if d.Contains(a.X+mid*(b.X-a.X), a.Y+mid*(b.Y-a.Y)) {
+			length += ts[j] - ts[j-1]
+		}
+	}
+	return length * math.Hypot(b.X-a.X, b.Y-a.Y)
+}
+
+// halfPlane is the set of locations (x, y) with nx*x + ny*y <= c.
+type halfPlane struct {
+	nx, ny, c float64
+}
+
+// bisector returns the half-plane of locations at least as near to (ax, ay) as to (bx, by).
+func bisector(ax, ay, bx, by float64) halfPlane {
+	return halfPlane{bx - ax, by - ay, (bx*bx + by*by - ax*ax - ay*ay) / 2}
+}
+
+// clip returns the part of the polygon with the given vertices inside the half-plane.
+// Sutherland & Hodgman, "Reentrant polygon clipping" (1974).
+func (h halfPlane) clip(polygon []voronoi.Vertex) []voronoi.Vertex {
+	clipped := make([]voronoi.Vertex, 0, len(polygon)+1)
+	for j, a := range polygon {
+		b := polygon[(j+1)%len(polygon)]
+		fa, fb := h.nx*a.X+h.ny*a.Y-h.c, h.nx*b.X+h.ny*b.Y-h.c
+		if fa <= 0 {
+			clipped = append(clipped, a)
+		}
+		if fa < 0 && fb > 0 || fa > 0 && fb < 0 {
+			t := fa / (fa - fb)
+			clipped = append(clipped, voronoi.NewVertex(a.X+t*(b.X-a.X), a.Y+t*(b.Y-a.Y)))
+		}
+	}
+	return clipped
+}
+
+// convexWindow returns the half-planes on the inside of each edge of the given convex polygon, which together hold
+// it.
+func convexWindow(polygon []voronoi.Vertex) []halfPlane {
+	window := make([]halfPlane, len(polygon))
+	// Keep the side of each edge that the polygon is on, whichever way round it is.
+	sign := 1.0
+	if voronoi.SignedArea(polygon) < 0 {
+		sign = -1
+	}
+	for j, a := range polygon {
+		b := polygon[(j+1)%len(polygon)]
+		ny, nx := sign*(a.X-b.X), sign*(b.Y-a.Y)
+		window[j] = halfPlane{nx, ny, nx*a.X + ny*a.Y}
+	}
+	return window
+}
+
+// clipLine returns the ends of the part of the line through (px, py) in the direction (dx, dy) that is inside all
+// of the half-planes, and whether that part is a segment of positive length.
+// Cyrus & Beck, "Generalized two- and three-dimensional clipping" (1978).
+func clipLine(px, py, dx, dy float64, planes []halfPlane) (voronoi.Vertex, voronoi.Vertex, bool) {
+	t0, t1 := math.Inf(-1), math.Inf(1)
+	for _, h := range planes {
+		// The part of the line with a + t*b <= 0 is inside the half-plane.
+		a, b := h.nx*px+h.ny*py-h.c, h.nx*dx+h.ny*dy
+		switch {
+		case b > 0:
+			t1 = math.Min(t1, -a/b)
+		case b < 0:
+			t0 = math.Max(t0, -a/b)
+		case a > 0:
+			return voronoi.Vertex{}, voronoi.Vertex{}, false
+		}
+	}
+	if !(t0 < t1) || math.IsInf(t0, 0) || math.IsInf(t1, 0) {
+		return voronoi.Vertex{}, voronoi.Vertex{}, false
+	}
+	return voronoi.NewVertex(px+t0*dx, py+t0*dy), voronoi.NewVertex(px+t1*dx, py+t1*dy), true
+}
@@ -0,0 +1,23 @@
+package interpolation
+
+import (
+	"errors"
+
+	"github.com/edwardbrowncross/naturalneighbour/delaunay"
+)
+
+// Errors returned by the Interpolator, in addition to those of the delaunay package. They are wrapped with details
+// of the failure, so should be checked for with errors.Is.
+var (
+	// ErrOutsideHull is returned, with ExtrapolateError, for coordinates outside the convex hull of the data points.
+	ErrOutsideHull = errors.New("point does not lie within the convex hull of the data")
+	// ErrOutsideDomain is returned, with NoDataError, for coordinates outside the domain of the Interpolator.
+	ErrOutsideDomain = errors.New("point does not lie within the domain")
+	// ErrNotDataPoint is returned for a point that is not one of the data points of the Interpolator.
+	ErrNotDataPoint = errors.New("point is not a data point of the interpolator")
+	// ErrInvalidArgument is returned when arguments do not fit together, such as slices of different lengths. It is
+	// delaunay.ErrInvalidArgument, so either can be checked for.
+	ErrInvalidArgument = delaunay.ErrInvalidArgument
+	// ErrUnsupported is returned for operations that the Interpolator's mode does not support.
+	ErrUnsupported = errors.New("not supported in this mode")
+)
@@ -0,0 +1,309 @@
+package interpolation
+
+import (
+	"math"
+	"sort"
+
+	"github.com/edwardbrowncross/naturalneighbour/delaunay"
+	"github.com/edwardbrowncross/naturalneighbour/geom"
+	"github.com/edwardbrowncross/naturalneighbour/voronoi"
+)
+
+// Extrapolation selects what happens when interpolating outside the convex hull of the data points.
+type Extrapolation int
+
+const (
+	// ExtrapolateNatural uses the natural neighbour coordinates of the location among the data points alone, with
+	// every voronoi cell clipped to the convex hull of the data points and the location, since outside the hull the
+	// cells are unbounded.
+	// The clipping also applies inside the hull wherever the voronoi cell of a point inserted at the location would
+	// reach outside it, so that the weights change continuously across the hull, though linear functions are then
+	// not reproduced exactly there. Elsewhere, the weights are the same as for the other policies.
+	ExtrapolateNatural Extrapolation = iota
+	// ExtrapolateError returns an error.
+	ExtrapolateError
+	// ExtrapolateNaN returns NaN.
+	ExtrapolateNaN
+	// ExtrapolateNearest returns the value at the nearest point on the convex hull, interpolated linearly between
+	// the data points at the ends of that edge of the hull.
+	ExtrapolateNearest
+)
+
+// getConvexHull returns the convex hull of the given points in anti-clockwise order. Nil points are ignored.
+// https://en.wikibooks.org/wiki/Algorithm_Implementation/Geometry/Convex_hull/Monotone_chain
+func getConvexHull(points []*delaunay.Point) []*delaunay.Point {
+	sorted := make([]*delaunay.Point, 0, len(points))
+	for _, p := range points {
+		if p != nil {
+			sorted = append(sorted, p)
+		}
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		if sorted[a].X != sorted[b].X {
+			return sorted[a].X < sorted[b].X
+		}
+		return sorted[a].Y < sorted[b].Y
+	})
+	if len(sorted) < 3 {
+		return sorted
+	}
+	hull := make([]*delaunay.Point, 0, 2*len(sorted))
+	// Build the lower hull left to right, then the upper hull right to left, dropping any point that does not
+	// make an anti-clockwise turn.
+	for pass := 0; pass < 2; pass++ {
+		start := len(hull)
+		for _, p := range sorted {
+			for len(hull) >= start+2 && !isAntiClockwise(hull[len(hull)-2], hull[len(hull)-1], p) {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, p)
+		}
+		// The last point of each pass is the first point of the next.
+		hull = hull[:len(hull)-1]
+		for a, b := 0, len(sorted)-1; a < b; a, b = a+1, b-1 {
+			sorted[a], sorted[b] = sorted[b], sorted[a]
+		}
+	}
+	return hull
+}
+
+// isAntiClockwise returns whether the three points make an anti-clockwise turn.
+func isAntiClockwise(a, b, c *delaunay.Point) bool {
+	return geom.Det3s(a.X, a.Y, b.X, b.Y, c.X, c.Y) > 0
+}
+
+// getNearestWeighting returns a weighting that interpolates linearly between the ends of the edge of the convex
+// hull nearest to the given coordinates.
+func (i *Interpolator) getNearestWeighting(x, y float64) *weighting {
+	w := &weighting{nearest: true}
+	if len(i.hull) == 0 {
+		return w
+	}
+	j, t, g := nearestOnHull(len(i.hull), i.hullPoint, x, y)
+	a, b := i.hull[j], i.hull[(j+1)%len(i.hull)]
+	switch t {
+	case 0:
+		w.points, w.weights, w.grads = []*delaunay.Point{a}, []float64{1}, []gradient{{}}
+	case 1:
+		w.points, w.weights, w.grads = []*delaunay.Point{b}, []float64{1}, []gradient{{}}
+	default:
+		w.points, w.weights = []*delaunay.Point{a, b}, []float64{1 - t, t}
+		w.grads = []gradient{{-g.dx, -g.dy}, {g.dx, g.dy}}
+	}
+	return w
+}
+
+// nearestOnHull finds the edge of a convex hull of n points that is nearest to the given coordinates, where the
+// coordinates of the j'th point of the hull are given by at. It returns the position in the hull of the point at
+// the start of the edge, the fraction t of the way along the edge to the next point that the nearest point lies,
+// and the partial derivatives of t.
+func nearestOnHull(n int, at func(j int) (float64, float64), x, y float64) (int, float64, gradient) {
+	best := -1.0
+	var nearest int
+	var nearestT float64
+	var g gradient
+	for j := 0; j < n; j++ {
+		ax, ay := at(j)
+		bx, by := at((j + 1) % n)
+		// Find the nearest point along the edge, as a fraction t of the way from a to b.
+		ex, ey := bx-ax, by-ay
+		l2 := ex*ex + ey*ey
+		t := 0.0
+		if l2 > 0 {
+			t = ((x-ax)*ex + (y-ay)*ey) / l2
+		}
+		if t < 0 {
+			t = 0
+		} else if t > 1 {
+			t = 1
+		}
+		dx, dy := ax+t*ex-x, ay+t*ey-y
+		d2 := dx*dx + dy*dy
+		if best >= 0 && d2 >= best {
+			continue
+		}
+		best, nearest, nearestT = d2, j, t
+		// Moving along the edge changes t by (ex, ey)/l2, while moving away from it does not change it.
+		g = gradient{}
+		if t > 0 && t < 1 {
+			g = gradient{ex / l2, ey / l2}
+		}
+	}
+	return nearest, nearestT, g
+}
+
+// getClippedWeighting returns the natural neighbours of the given coordinates, which lie within the given leaf
+// triangle, and their weights for ExtrapolateNatural, with the voronoi cells clipped to the convex hull of the data
+// points and the location. Returns nil if no data point has a weight.
+// The clipped cells do not have the simple derivatives of the usual ones, so the partial derivatives of the weights
+// are optionally found by central differences.
+func (i *Interpolator) getClippedWeighting(leaf *delaunay.Triangle, x, y float64, withGradient bool) *weighting {
+	if len(i.hull) < 3 {
+		return nil
+	}
+	sites := getCavitySites(leaf, x, y)
+	coordinates := func(x, y float64) []float64 {
+		return getClippedCoordinates(sites, hullWith(len(i.hull), i.hullPoint, x, y), i.opts.Mode, i.opts.Domain, x, y)
+	}
+	weights := coordinates(x, y)
+	if weights == nil {
+		return nil
+	}
+	var dx, dy []float64
+	if withGradient {
+		// Step a small fraction of the distance to the nearest site, which is never zero away from the data points.
+		h := math.Inf(1)
+		for _, s := range sites {
+			h = math.Min(h, 1e-6*math.Hypot(s.x-x, s.y-y))
+		}
+		difference := func(ax, ay, bx, by float64) []float64 {
+			d := make([]float64, len(sites))
+			for k, w := range coordinates(ax, ay) {
+				d[k] -= w / (2 * h)
+			}
+			for k, w := range coordinates(bx, by) {
+				d[k] += w / (2 * h)
+			}
+			return d
+		}
+		dx, dy = difference(x-h, y, x+h, y), difference(x, y-h, x, y+h)
+	}
+	w := &weighting{leaf: leaf}
+	for j, s := range sites {
+		if weights[j] <= 0 {
+			continue
+		}
+		w.points = append(w.points, s.p)
+		w.weights = append(w.weights, weights[j])
+		if withGradient {
+			w.grads = append(w.grads, gradient{dx[j], dy[j]})
+		}
+	}
+	return w
+}
+
+// getClippedCoordinates returns the natural neighbour coordinates of the given location among the given sites,
+// which must include all of its natural neighbours, with every voronoi cell clipped to the given convex window,
+// which holds the location. The weights are in the same order as the sites, and are nil if none of them is
+// positive. If a domain is given, each weight only counts the part of it inside the domain.
+func getClippedCoordinates(sites []site, window []voronoi.Vertex, mode Mode, d *Domain, x, y float64) []float64 {
+	planes := convexWindow(window)
+	cell := window
+	for _, s := range sites {
+		cell = bisector(x, y, s.x, s.y).clip(cell)
+	}
+	weights := make([]float64, len(sites))
+	total := 0.0
+	for j, s := range sites {
+		if mode == Laplace {
+			// The facet shared with the site is the part of their bisector that is inside the window and nearer to the
+			// location than to any other site.
+			facetPlanes := append([]halfPlane(nil), planes...)
+			for k, m := range sites {
+				if k != j {
+					facetPlanes = append(facetPlanes, bisector(x, y, m.x, m.y))
+				}
+			}
+			f0, f1, ok := clipLine((x+s.x)/2, (y+s.y)/2, y-s.y, s.x-x, facetPlanes)
+			if !ok {
+				continue
+			}
+			length := math.Hypot(f1.X-f0.X, f1.Y-f0.Y)
+			if d != nil {
+				length = d.segmentLength(f0, f1)
+			}
+			weights[j] = length / math.Hypot(s.x-x, s.y-y)
+		} else {
+			// The area taken from each site is the part of the cell nearer to it than to any other site.
+			taken := cell
+			for k, m := range sites {
+				if k != j {
+					taken = bisector(s.x, s.y, m.x, m.y).clip(taken)
+				}
+			}
+			if d != nil {
+				weights[j] = d.convexArea(taken)
+			} else {
+				weights[j] = math.Abs(voronoi.SignedArea(taken))
+			}
+		}
+		total += weights[j]
+	}
+	if !(total > 0) {
+		return nil
+	}
+	for j := range weights {
+		weights[j] /= total
+	}
+	return weights
+}
+
+// hullWith returns the convex hull of the given location and a convex hull of n points, anti-clockwise, where the
+// coordinates of the j'th point of the hull are given by at.
+func hullWith(n int, at func(j int) (float64, float64), x, y float64) []voronoi.Vertex {
+	// visible returns whether the location is strictly outside the edge of the hull from the j'th point.
+	visible := func(j int) bool {
+		ax, ay := at(j % n)
+		bx, by := at((j + 1) % n)
+		return geom.Orient2d(ax, ay, bx, by, x, y) < 0
+	}
+	hull := make([]voronoi.Vertex, 0, n+1)
+	for j := 0; j < n; j++ {
+		// The points between edges that the location can see are inside the new hull, and the location joins it
+		// after the last point before them.
+		before, after := visible(j+n-1), visible(j)
+		if !before || !after {
+			hull = append(hull, voronoi.NewVertex(at(j)))
+		}
+		if !before && after {
+			hull = append(hull, voronoi.NewVertex(x, y))
+		}
+	}
+	return hull
+}
+
+// isInHull returns whether the given coordinates are inside or on a convex hull of n points, anti-clockwise, where
+// the coordinates of the j'th point of the hull are given by at.
+func isInHull(n int, at func(j int) (float64, float64), x, y float64) bool {
+	if n < 3 {
+		return false
+	}
+	ox, oy := at(0)
+	orient := func(j int) float64 {
+		px, py := at(j)
+		return geom.Orient2d(ox, oy, px, py, x, y)
+	}
+	// Binary search for the triangle of the fan from the first point that the location is in.
+	if orient(1) < 0 || orient(n-1) > 0 {
+		return false
+	}
+	lo, hi := 1, n-1
+	for hi-lo > 1 {
+		if mid := (lo + hi) / 2; orient(mid) >= 0 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	ax, ay := at(lo)
+	bx, by := at(hi)
+	return geom.Orient2d(ax, ay, bx, by, x, y) >= 0
+}
+
+// isCellInHull returns whether the voronoi cell of a point inserted at the query location of the given neighbours
+// lies within a convex hull of n points, anti-clockwise, where the coordinates of the j'th point of the hull are
+// given by at. Without neighbours, the cell is unbounded.
+func isCellInHull(neighbours []neighbour, n int, at func(j int) (float64, float64)) bool {
+	if len(neighbours) == 0 {
+		return false
+	}
+	// The cell is convex, and every vertex of it is an end of a neighbour's facet.
+	for _, nb := range neighbours {
+		for _, f := range nb.facet {
+			if !isInHull(n, at, f.X, f.Y) {
+				return false
+			}
+		}
+	}
+	return true
+}
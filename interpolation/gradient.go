@@ -0,0 +1,100 @@
+package interpolation
+
+import (
+	"math"
+
+	"github.com/edwardbrowncross/naturalneighbour/voronoi"
+)
+
+// InterpolateWithGradient returns the interpolated value at the given x and y coordinates along with the partial
+// derivatives of the interpolated surface there. For points with several values, it interpolates the first.
+// The derivatives are calculated analytically from the derivatives of the natural neighbour coordinates, so are
+// exact (up to floating point error) rather than estimated by finite differences. The exception is where
+// ExtrapolateNatural clips the voronoi cells to the convex hull, where the derivatives of the weights are estimated
+// by central differences.
+// The surface is not differentiable at the data points, or in Sibson and Laplace modes on the circumcircles of the
+// triangulation. On the circumcircles, the derivative from one side is returned. At the data points, the estimated
+// gradient is returned in SibsonC1 mode, and zero derivatives in other modes. Near the edges of Options.Domain, the
+// derivatives do not account for the voronoi cells being clipped to it.
+func (i *Interpolator) InterpolateWithGradient(x, y float64) (value, dx, dy float64, err error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	w, err := i.getWeighting(nil, x, y, true)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if w == nil {
+		return math.NaN(), math.NaN(), math.NaN(), nil
+	}
+	if w.noData {
+		return i.opts.FillValue, 0, 0, nil
+	}
+	if i.opts.Mode == SibsonC1 && !w.nearest {
+		value, g := i.sibsonC1(w.points, w.weights, w.grads, x, y, 0)
+		return value, g.dx, g.dy, nil
+	}
+	for j, p := range w.points {
+		v := p.GetValue(0)
+		value += w.weights[j] * v
+		dx += w.grads[j].dx * v
+		dy += w.grads[j].dy * v
+	}
+	return value, dx, dy, nil
+}
+
+// getSibsonWeight returns the unnormalised Sibson weight of the given neighbour of the query location (x, y): the
+// area of its voronoi cell that the query would take. Optionally also returns its partial derivatives.
+func getSibsonWeight(n neighbour, x, y float64, withGradient bool) (float64, gradient) {
+	if !withGradient {
+		return n.area, gradient{}
+	}
+	// As the query moves, the stolen area only changes along the facet, which moves with the bisector of the query
+	// and the neighbour. This gives a gradient of s/r times the vector from the query to the facet's midpoint, where
+	// s is the facet length and r the distance to the neighbour.
+	f0, f1 := n.facet[0], n.facet[1]
+	s := math.Hypot(f1.X-f0.X, f1.Y-f0.Y)
+	r := math.Hypot(x-n.x, y-n.y)
+	return n.area, gradient{
+		dx: s / r * ((f0.X+f1.X)/2 - x),
+		dy: s / r * ((f0.Y+f1.Y)/2 - y),
+	}
+}
+
+// getLaplaceWeight returns the unnormalised Laplace weight of the given neighbour of the query location (x, y): the
+// length of the voronoi edge it would share with the query divided by its distance from it. Optionally also returns
+// its partial derivatives.
+func getLaplaceWeight(n neighbour, x, y float64, withGradient bool) (float64, gradient) {
+	f0, f1 := n.facet[0], n.facet[1]
+	fx, fy := f1.X-f0.X, f1.Y-f0.Y
+	s := math.Hypot(fx, fy)
+	px, py := x-n.x, y-n.y
+	r := math.Hypot(px, py)
+	if !withGradient || s == 0 {
+		return s / r, gradient{}
+	}
+	// The facet ends move along the bisectors of the neighbour and the adjacent points as the query moves.
+	j0 := circumcenterGradient(f0.X, f0.Y, n.adjacent[0], n.x, n.y, x, y, fx/s, fy/s)
+	j1 := circumcenterGradient(f1.X, f1.Y, n.adjacent[1], n.x, n.y, x, y, fx/s, fy/s)
+	return s / r, gradient{
+		dx: (j1.dx-j0.dx)/r - s*px/(r*r*r),
+		dy: (j1.dy-j0.dy)/r - s*py/(r*r*r),
+	}
+}
+
+// circumcenterGradient returns the gradient, with respect to the query location (x, y), of the projection onto
+// the unit vector (ex, ey) of the circumcenter (cx, cy) of the query location, point a and the neighbour (nx, ny).
+// The circumcenter moves along the bisector of a and the neighbour, at the rate that keeps it equidistant from the
+// neighbour and the query.
+func circumcenterGradient(cx, cy float64, a voronoi.Vertex, nx, ny, x, y, ex, ey float64) gradient {
+	// Direction of the bisector of a and the neighbour.
+	bx, by := ny-a.Y, a.X-nx
+	denom := (nx-x)*bx + (ny-y)*by
+	if denom == 0 {
+		return gradient{}
+	}
+	f := (bx*ex + by*ey) / denom
+	return gradient{
+		dx: f * (cx - x),
+		dy: f * (cy - y),
+	}
+}
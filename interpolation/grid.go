@@ -0,0 +1,83 @@
+package interpolation
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/edwardbrowncross/naturalneighbour/delaunay"
+)
+
+// InterpolateGrid interpolates at every point of a regular nx by ny grid spanning the given bounds (inclusive),
+// returning the values in row-major order: the value at column c of row r is at index r*nx+c, with row 0 at minY
+// and column 0 at minX. For points with several values, it interpolates the first.
+// Points outside the convex hull of the data are treated according to Options.Extrapolation.
+// Rows are spread across Options.Workers goroutines. Consecutive samples start their search from the triangle
+// containing the previous sample, so this is much faster than calling Interpolate for every point.
+func (i *Interpolator) InterpolateGrid(minX, minY, maxX, maxY float64, nx, ny int) ([]float64, error) {
+	if nx <= 0 || ny <= 0 {
+		return []float64{}, nil
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	result := make([]float64, nx*ny)
+	workers := i.opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	rows := make(chan int)
+	failed := make(chan struct{})
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var err error
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var rowHint *delaunay.Triangle
+			for r := range rows {
+				y := gridCoord(minY, maxY, r, ny)
+				hint := rowHint
+				for c := 0; c < nx; c++ {
+					x := gridCoord(minX, maxX, c, nx)
+					w, e := i.getWeighting(hint, x, y, false)
+					if e != nil {
+						errOnce.Do(func() {
+							err = e
+							close(failed)
+						})
+						return
+					}
+					if w != nil && w.leaf != nil {
+						if c == 0 {
+							rowHint = w.leaf
+						}
+						hint = w.leaf
+					}
+					result[r*nx+c] = i.interpolate(w, x, y, 0)
+				}
+			}
+		}()
+	}
+feed:
+	for r := 0; r < ny; r++ {
+		select {
+		case rows <- r:
+		case <-failed:
+			break feed
+		}
+	}
+	close(rows)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// gridCoord returns the coordinate of the i'th of n evenly spaced samples between min and max inclusive.
+func gridCoord(min, max float64, i, n int) float64 {
+	if n == 1 {
+		return min
+	}
+	return min + (max-min)*float64(i)/float64(n-1)
+}
@@ -1,56 +1,295 @@
 package interpolation
 
 import (
+	"fmt"
+	"math"
+	"sync"
+
 	"github.com/edwardbrowncross/naturalneighbour/delaunay"
-	"github.com/edwardbrowncross/naturalneighbour/voronoi"
 )
 
 // Interpolator provides natural neighbour interpolation within a set of points.
+// Interpolation does not modify the underlying triangulation, so an Interpolator may be used from multiple
+// goroutines at once, including while its values are being updated.
 type Interpolator struct {
+	mu        sync.RWMutex // Held for writing while the values of the points are updated.
+	points    []*delaunay.Point
+	index     map[*delaunay.Point]int // Position of each point in points.
 	t         *delaunay.Triangulation
-	areaCache map[*delaunay.Point]float64
+	opts      Options
+	gradients map[*delaunay.Point][]gradient // Estimated gradient of each channel at each data point, in SibsonC1 mode.
+	hull      []*delaunay.Point              // Convex hull of the data points, anti-clockwise.
+	channels  int                            // Number of values associated with each data point.
+}
+
+// Mode selects how the values of natural neighbours are weighted.
+type Mode int
+
+const (
+	// Sibson weights each neighbour by the area of its voronoi cell that a point inserted at the query location
+	// would take.
+	Sibson Mode = iota
+	// Laplace (non-Sibsonian) weights each neighbour by the length of the voronoi edge it would share with a point
+	// inserted at the query location, divided by its distance from the query location.
+	Laplace
+	// SibsonC1 blends tangent planes at the natural neighbours using Sibson's C1 interpolant, weighted by Sibson
+	// coordinates. Unlike Sibson and Laplace, it gives a surface without creases at the data points.
+	// The gradient at each data point is estimated from the points it is connected to.
+	SibsonC1
+)
+
+// Options configures an Interpolator.
+type Options struct {
+	// Mode selects how natural neighbours are weighted. Defaults to Sibson.
+	Mode Mode
+	// Extrapolation selects what happens when interpolating outside the convex hull of the data points.
+	// Defaults to ExtrapolateNatural.
+	Extrapolation Extrapolation
+	// Workers is the number of goroutines InterpolateGrid spreads its rows across, and that building the
+	// triangulation with delaunay.ConstructDivideAndConquer uses. If zero, runtime.GOMAXPROCS(0) is used.
+	Workers int
+	// Duplicates selects what happens when several data points have the same coordinates.
+	// Defaults to delaunay.DuplicateError. delaunay.DuplicateAverage overwrites the values of the first point of
+	// each group given.
+	Duplicates delaunay.DuplicatePolicy
+	// Location selects how the triangulation locates points. delaunay.LocateWalk uses much less memory for large
+	// numbers of points. Defaults to delaunay.LocateHistory.
+	Location delaunay.Location
+	// Construction selects how the triangulation is built. delaunay.ConstructDivideAndConquer is much faster for
+	// large numbers of points. Defaults to delaunay.ConstructIncremental.
+	Construction delaunay.Construction
+	// Domain restricts where values are given, such as to land outside of lakes. Locations outside it are treated
+	// according to NoData, and the voronoi cells that weight the natural neighbours are clipped to it, so that only
+	// the parts of them inside it count. If nil, values are given everywhere.
+	Domain *Domain
+	// NoData selects what happens when interpolating outside the Domain. Defaults to NoDataNaN.
+	NoData NoData
+	// FillValue is the value given outside the Domain with NoDataFill.
+	FillValue float64
 }
 
-// New creates a new Interpolator using the given points.
+// New creates a new Interpolator using the given points and default options.
 func New(points []*delaunay.Point) (*Interpolator, error) {
-	t, err := delaunay.NewTriangulation(points)
-	return &Interpolator{t, map[*delaunay.Point]float64{}}, err
+	return NewWithOptions(points, Options{})
+}
+
+// NewWithOptions creates a new Interpolator using the given points and options.
+// Every point must have the same number of values.
+// Points left out because of Options.Duplicates are treated as if they had been removed with RemovePoint.
+func NewWithOptions(points []*delaunay.Point, opts Options) (*Interpolator, error) {
+	if opts.Domain != nil {
+		if err := opts.Domain.validate(); err != nil {
+			return nil, err
+		}
+	}
+	channels := 1
+	for idx, p := range points {
+		if idx == 0 {
+			channels = p.GetNumValues()
+		} else if p.GetNumValues() != channels {
+			return nil, fmt.Errorf("%w: point %d has %d values but point 0 has %d", delaunay.ErrValueCount, idx, p.GetNumValues(), channels)
+		}
+	}
+	t, err := delaunay.NewTriangulationWithOptions(points, delaunay.Options{
+		Duplicates:   opts.Duplicates,
+		Location:     opts.Location,
+		Construction: opts.Construction,
+		Workers:      opts.Workers,
+	})
+	i := &Interpolator{
+		points:   append([]*delaunay.Point(nil), points...),
+		index:    make(map[*delaunay.Point]int, len(points)),
+		t:        t,
+		opts:     opts,
+		channels: channels,
+	}
+	if err != nil {
+		return i, err
+	}
+	for idx, p := range points {
+		if !t.HasPoint(p) {
+			i.points[idx] = nil
+			continue
+		}
+		i.index[p] = idx
+	}
+	i.hull = getConvexHull(i.points)
+	if opts.Mode == SibsonC1 {
+		i.gradients = make(map[*delaunay.Point][]gradient, len(i.index))
+		for p := range i.index {
+			i.gradients[p] = estimateGradients(p, channels)
+		}
+	}
+	return i, nil
 }
 
 // Interpolate returns the interpolated value at the given x and y coordinates using natural neighbour interpolation.
+// For points with several values, it interpolates the first. At a data point, its value is returned exactly.
 // https://pdfs.semanticscholar.org/52ca/255573eded0e4371fe2ced980b196636718d.pdf
 func (i *Interpolator) Interpolate(x, y float64) (float64, error) {
-	// Create a new point and add it to the triangulation.
-	p := delaunay.NewPoint(x, y, 0)
-	undo, err := i.t.AddPoint(p)
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	w, err := i.getWeighting(nil, x, y, false)
 	if err != nil {
 		return 0, err
 	}
-	// Calculate the area of the voronoi cells of the points linked to the new point.
-	neighbours := p.GetConnected()
-	areasAfter := make([]float64, len(neighbours))
-	// Calculate the area of the new test point's voronoi cells.
-	for i, n := range neighbours {
-		areasAfter[i] = voronoi.NewRegion(n).GetArea()
-	}
-	totalArea := voronoi.NewRegion(p).GetArea()
-	undo()
-	// Calculate the area of the same points without the new point in the triangulation.
-	areasBefore := make([]float64, len(neighbours))
-	for idx, n := range neighbours {
-		if value, found := i.areaCache[n]; !found {
-			area := voronoi.NewRegion(n).GetArea()
-			i.areaCache[n] = area
-			areasBefore[idx] = area
-		} else {
-			areasBefore[idx] = value
-		}
-	}
-	// Take a weighted average of the values of the points the new test point was connected to.
-	// Weighting is the percentage of the test point's voronoi cell that was stolen from each neighbour point.
+	return i.interpolate(w, x, y, 0), nil
+}
+
+// InterpolateAll returns the interpolated value of every one of the points' values at the given x and y
+// coordinates. The natural neighbours and their weights are only found once, so this is much faster than
+// interpolating each value separately.
+func (i *Interpolator) InterpolateAll(x, y float64) ([]float64, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	w, err := i.getWeighting(nil, x, y, false)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]float64, i.channels)
+	for c := range values {
+		values[c] = i.interpolate(w, x, y, c)
+	}
+	return values, nil
+}
+
+// Weights returns the natural neighbours of the given x and y coordinates along with their natural neighbour
+// coordinates. In Sibson and SibsonC1 modes, these are the fraction of the voronoi cell of a point inserted there
+// that would be taken from each neighbour. In Laplace mode, they are proportional to the length of the voronoi edge
+// each neighbour would share with that point divided by its distance from it.
+// The weights sum to one. Except in SibsonC1 mode, Interpolate returns the sum of each neighbour's value multiplied
+// by its weight.
+// Outside the convex hull of the data points, the weights depend on Options.Extrapolation. For ExtrapolateNaN, no
+// neighbours are returned. Nor are they outside the Domain, unless Options.NoData gives an error.
+func (i *Interpolator) Weights(x, y float64) ([]*delaunay.Point, []float64, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	w, err := i.getWeighting(nil, x, y, false)
+	if err != nil || w == nil || w.noData {
+		return nil, nil, err
+	}
+	return w.points, w.weights, nil
+}
+
+// weighting is the set of data points, and their weights, used to interpolate at a location.
+type weighting struct {
+	points  []*delaunay.Point
+	weights []float64
+	grads   []gradient         // Partial derivatives of the weights with respect to the location, if requested.
+	leaf    *delaunay.Triangle // Leaf triangle containing the location, if any, for use as a search hint.
+	nearest bool               // Whether the weights interpolate along the convex hull, rather than being natural neighbour coordinates.
+	noData  bool               // Whether the location is outside the domain, and given Options.FillValue.
+}
+
+// interpolate returns the interpolated value of the given channel at the given coordinates using the given weighting.
+func (i *Interpolator) interpolate(w *weighting, x, y float64, channel int) float64 {
+	if w == nil {
+		return math.NaN()
+	}
+	if w.noData {
+		return i.opts.FillValue
+	}
+	if i.opts.Mode == SibsonC1 && !w.nearest {
+		return i.interpolateC1(w.points, w.weights, x, y, channel)
+	}
+	// Take a weighted average of the values of the points a new point here would be connected to.
 	total := 0.0
-	for i, n := range neighbours {
-		total += n.Value * (areasBefore[i] - areasAfter[i])
+	for j, p := range w.points {
+		total += p.GetValue(channel) * w.weights[j]
+	}
+	return total
+}
+
+// getWeighting finds the data points and weights to interpolate with at the given coordinates, starting the search
+// for them from the hint triangle, which may be nil.
+// If the coordinates are outside of the convex hull of the data points, applies the extrapolation policy, returning
+// nil for ExtrapolateNaN. Outside the domain, applies the no data policy in the same way.
+func (i *Interpolator) getWeighting(hint *delaunay.Triangle, x, y float64, withGradient bool) (*weighting, error) {
+	if d := i.opts.Domain; d != nil && !d.Contains(x, y) {
+		switch i.opts.NoData {
+		case NoDataError:
+			return nil, fmt.Errorf("%w: (%f,%f)", ErrOutsideDomain, x, y)
+		case NoDataFill:
+			return &weighting{noData: true}, nil
+		default:
+			return nil, nil
+		}
+	}
+	return i.getSurfaceWeighting(hint, x, y, withGradient)
+}
+
+// getSurfaceWeighting is getWeighting without the domain: it finds the data points and weights of the interpolated
+// surface at the given coordinates, even where they are outside the domain.
+func (i *Interpolator) getSurfaceWeighting(hint *delaunay.Triangle, x, y float64, withGradient bool) (*weighting, error) {
+	leaf, err := i.t.LocateFrom(hint, x, y)
+	// At a data point, its own value is the exact answer, and the natural neighbour weights are undefined.
+	if err == nil {
+		if p := i.getSite(leaf, x, y); p != nil {
+			return &weighting{points: []*delaunay.Point{p}, weights: []float64{1}, grads: []gradient{{}}, leaf: leaf}, nil
+		}
+	}
+	// Ghost triangles cover everything outside the hull, so any other leaf is inside it.
+	if err == nil && !leaf.IsGhost() {
+		neighbours := getNeighbours(getCavity(leaf, x, y), x, y)
+		if i.opts.Extrapolation == ExtrapolateNatural && !isCellInHull(neighbours, len(i.hull), i.hullPoint) {
+			if w := i.getClippedWeighting(leaf, x, y, withGradient); w != nil {
+				return w, nil
+			}
+		} else if w := i.getNaturalWeighting(leaf, neighbours, x, y, withGradient); len(w.points) != 0 {
+			return w, nil
+		}
+		return i.getNearestWeighting(x, y), nil
+	}
+	switch i.opts.Extrapolation {
+	case ExtrapolateNaN:
+		return nil, nil
+	case ExtrapolateNearest:
+		return i.getNearestWeighting(x, y), nil
+	case ExtrapolateNatural:
+		if err == nil {
+			if w := i.getClippedWeighting(leaf, x, y, withGradient); w != nil {
+				return w, nil
+			}
+		}
+		return i.getNearestWeighting(x, y), nil
+	default:
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: (%f,%f)", ErrOutsideHull, x, y)
+	}
+}
+
+// getNaturalWeighting returns the natural neighbours of the given coordinates, which lie within the given leaf
+// triangle, from among the neighbours found from its cavity, their normalised weights and, optionally, the partial
+// derivatives of those weights.
+// On the hull, where the neighbours would include the point at infinity, there are no neighbours.
+func (i *Interpolator) getNaturalWeighting(leaf *delaunay.Triangle, neighbours []neighbour, x, y float64, withGradient bool) *weighting {
+	sites, weights, grads := getNaturalCoordinates(neighbours, i.opts.Mode, i.opts.Domain, x, y, withGradient)
+	w := &weighting{
+		points:  make([]*delaunay.Point, len(sites)),
+		weights: weights,
+		grads:   grads,
+		leaf:    leaf,
+	}
+	for j, s := range sites {
+		w.points[j] = s.p
+	}
+	return w
+}
+
+// hullPoint returns the coordinates of the j'th point of the convex hull of the data points.
+func (i *Interpolator) hullPoint(j int) (float64, float64) {
+	return i.hull[j].X, i.hull[j].Y
+}
+
+// getSite returns the data point among the vertices of the given triangle that is exactly at the given coordinates,
+// or nil if there is none.
+func (i *Interpolator) getSite(t *delaunay.Triangle, x, y float64) *delaunay.Point {
+	for _, p := range t.Points {
+		if p.X == x && p.Y == y && !p.IsInfinite() {
+			return p
+		}
 	}
-	return total / totalArea, nil
+	return nil
 }
@@ -1,11 +1,14 @@
 package interpolation
 
 import (
+	"errors"
 	"math"
 	"math/rand"
+	"sync"
 	"testing"
 
 	"github.com/edwardbrowncross/naturalneighbour/delaunay"
+	"github.com/edwardbrowncross/naturalneighbour/voronoi"
 )
 
 var Epsilon float64 = 0.00000001
@@ -34,9 +37,1027 @@ func TestInterpolator(t *testing.T) {
 	}
 }
 
+func TestWeights(t *testing.T) {
+	for _, mode := range []Mode{Sibson, Laplace} {
+		testWeights(t, mode)
+	}
+}
+
+func testWeights(t *testing.T, mode Mode) {
+	rand.Seed(0)
+	points := make([]*delaunay.Point, 1000)
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), rand.Float64())
+	}
+	interpolator, err := NewWithOptions(points, Options{Mode: mode})
+	if err != nil {
+		t.Fatalf("error creating interpolator: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		x, y := 0.1+0.8*rand.Float64(), 0.1+0.8*rand.Float64()
+		neighbours, weights, err := interpolator.Weights(x, y)
+		if err != nil {
+			t.Fatalf("error getting weights: %v", err)
+		}
+		// Weights should sum to one, reproduce the interpolated value and have the query point as their centroid.
+		sum, value, cx, cy := 0.0, 0.0, 0.0, 0.0
+		for j, n := range neighbours {
+			sum += weights[j]
+			value += weights[j] * n.Value
+			cx += weights[j] * n.X
+			cy += weights[j] * n.Y
+		}
+		expected, err := interpolator.Interpolate(x, y)
+		if err != nil {
+			t.Fatalf("error interpolating point: %v", err)
+		}
+		if math.Abs(sum-1) > Epsilon {
+			t.Errorf("expected weights to sum to 1 but got %v", sum)
+		}
+		if math.Abs(value-expected) > Epsilon {
+			t.Errorf("expected weighted value of %v but got %v", expected, value)
+		}
+		if math.Abs(cx-x) > Epsilon || math.Abs(cy-y) > Epsilon {
+			t.Errorf("expected weighted centroid of (%v,%v) but got (%v,%v)", x, y, cx, cy)
+		}
+	}
+}
+
+func TestSibsonC1(t *testing.T) {
+	// Linear data should be reproduced exactly, while smooth data should be fit better than by Sibson.
+	linear := func(x, y float64) float64 { return 1 + 2*x - 3*y }
+	quadratic := func(x, y float64) float64 { return x*x + 2*y*y - x*y }
+	newInterpolator := func(f func(x, y float64) float64, mode Mode) *Interpolator {
+		rand.Seed(0)
+		points := make([]*delaunay.Point, 500)
+		for i := range points {
+			x, y := rand.Float64(), rand.Float64()
+			points[i] = NewPoint(x, y, f(x, y))
+		}
+		interpolator, err := NewWithOptions(points, Options{Mode: mode})
+		if err != nil {
+			t.Fatalf("error creating interpolator: %v", err)
+		}
+		return interpolator
+	}
+	linearC1 := newInterpolator(linear, SibsonC1)
+	quadraticC0 := newInterpolator(quadratic, Sibson)
+	quadraticC1 := newInterpolator(quadratic, SibsonC1)
+	errC0, errC1 := 0.0, 0.0
+	for i := 0; i < 100; i++ {
+		x, y := 0.2+0.6*rand.Float64(), 0.2+0.6*rand.Float64()
+		result, err := linearC1.Interpolate(x, y)
+		if err != nil {
+			t.Fatalf("error interpolating point: %v", err)
+		}
+		if math.Abs(result-linear(x, y)) > Epsilon {
+			t.Errorf("expected result of %v at (%v,%v) but got %v", linear(x, y), x, y, result)
+		}
+		c0, _ := quadraticC0.Interpolate(x, y)
+		c1, _ := quadraticC1.Interpolate(x, y)
+		errC0 += math.Abs(c0 - quadratic(x, y))
+		errC1 += math.Abs(c1 - quadratic(x, y))
+	}
+	if errC1 >= errC0 {
+		t.Errorf("expected C1 error (%v) to be less than C0 error (%v)", errC1, errC0)
+	}
+}
+
+func TestInterpolateWithGradient(t *testing.T) {
+	for _, mode := range []Mode{Sibson, Laplace, SibsonC1} {
+		rand.Seed(0)
+		points := make([]*delaunay.Point, 500)
+		for i := range points {
+			points[i] = NewPoint(rand.Float64(), rand.Float64(), rand.Float64())
+		}
+		interpolator, err := NewWithOptions(points, Options{Mode: mode})
+		if err != nil {
+			t.Fatalf("error creating interpolator: %v", err)
+		}
+		// Compare against central differences.
+		h := 1e-7
+		for i := 0; i < 100; i++ {
+			x, y := 0.2+0.6*rand.Float64(), 0.2+0.6*rand.Float64()
+			value, dx, dy, err := interpolator.InterpolateWithGradient(x, y)
+			if err != nil {
+				t.Fatalf("error interpolating point: %v", err)
+			}
+			expected, _ := interpolator.Interpolate(x, y)
+			x1, _ := interpolator.Interpolate(x+h, y)
+			x0, _ := interpolator.Interpolate(x-h, y)
+			y1, _ := interpolator.Interpolate(x, y+h)
+			y0, _ := interpolator.Interpolate(x, y-h)
+			if math.Abs(value-expected) > Epsilon {
+				t.Errorf("mode %v: expected value of %v at (%v,%v) but got %v", mode, expected, x, y, value)
+			}
+			if math.Abs(dx-(x1-x0)/(2*h)) > 1e-4*(1+math.Abs(dx)) || math.Abs(dy-(y1-y0)/(2*h)) > 1e-4*(1+math.Abs(dy)) {
+				t.Errorf("mode %v: expected gradient of (%v,%v) at (%v,%v) but got (%v,%v)", mode, (x1-x0)/(2*h), (y1-y0)/(2*h), x, y, dx, dy)
+			}
+		}
+	}
+}
+
+func TestExtrapolation(t *testing.T) {
+	newInterpolator := func(opts Options) *Interpolator {
+		r := rand.New(rand.NewSource(0))
+		points := []*delaunay.Point{NewPoint(0, 0, 0), NewPoint(1, 0, 1), NewPoint(1, 1, 2), NewPoint(0, 1, 1)}
+		for i := 0; i < 100; i++ {
+			x, y := r.Float64(), r.Float64()
+			points = append(points, NewPoint(x, y, x+y))
+		}
+		interpolator, err := NewWithOptions(points, opts)
+		if err != nil {
+			t.Fatalf("error creating interpolator: %v", err)
+		}
+		return interpolator
+	}
+	// Natural neighbour coordinates reproduce linear functions, right up to and along the hull.
+	interpolator := newInterpolator(Options{Extrapolation: ExtrapolateError})
+	for _, q := range [][2]float64{{0.5, 0.0001}, {0.9999, 0.5}, {0.0001, 0.0001}, {0.5, 0}, {1, 0.3}} {
+		result, err := interpolator.Interpolate(q[0], q[1])
+		if err != nil {
+			t.Fatalf("error interpolating point: %v", err)
+		}
+		if math.Abs(result-(q[0]+q[1])) > Epsilon {
+			t.Errorf("expected result of about %v at %v but got %v", q[0]+q[1], q, result)
+		}
+	}
+	if _, err := interpolator.Interpolate(1.5, 0.5); !errors.Is(err, ErrOutsideHull) {
+		t.Errorf("expected error interpolating outside hull")
+	}
+	if result, err := newInterpolator(Options{Extrapolation: ExtrapolateNaN}).Interpolate(1.5, 0.5); err != nil || !math.IsNaN(result) {
+		t.Errorf("expected NaN interpolating outside hull but got %v (%v)", result, err)
+	}
+	interpolator = newInterpolator(Options{Extrapolation: ExtrapolateNearest})
+	for _, c := range []struct{ x, y, expected float64 }{{1.5, 0.5, 1.5}, {2, 2, 2}, {100, -100, 1}, {0.5, -0.5, 0.5}} {
+		if result, err := interpolator.Interpolate(c.x, c.y); err != nil || math.Abs(result-c.expected) > Epsilon {
+			t.Errorf("expected nearest result of %v at (%v,%v) but got %v (%v)", c.expected, c.x, c.y, result, err)
+		}
+	}
+	for _, mode := range []Mode{Sibson, Laplace, SibsonC1} {
+		// ExtrapolateNatural is the default.
+		interpolator = newInterpolator(Options{Mode: mode})
+		for _, q := range [][2]float64{{1.2, 0.5}, {0.5, -0.3}, {-0.1, 1.1}, {5, 5}} {
+			neighbours, weights, err := interpolator.Weights(q[0], q[1])
+			if err != nil {
+				t.Fatalf("mode %v: error getting weights: %v", mode, err)
+			}
+			// Unlike ExtrapolateNearest, the weights are not just those of the ends of the nearest edge of the hull,
+			// except beyond its corners.
+			if q[1] > -0.5 && q[1] < 1 && len(neighbours) < 3 {
+				t.Errorf("mode %v: expected natural neighbours of %v beyond the nearest hull edge but got %v", mode, q, len(neighbours))
+			}
+			sum := 0.0
+			for j, n := range neighbours {
+				if n.X < 0 || n.X > 1 || n.Y < 0 || n.Y > 1 || weights[j] <= 0 {
+					t.Errorf("mode %v: expected only data points as neighbours of %v but got (%v,%v) with weight %v", mode, q, n.X, n.Y, weights[j])
+				}
+				sum += weights[j]
+			}
+			if math.Abs(sum-1) > Epsilon {
+				t.Errorf("mode %v: expected weights to sum to 1 but got %v", mode, sum)
+			}
+			// The derivatives match the change in the interpolated value.
+			value, dx, dy, err := interpolator.InterpolateWithGradient(q[0], q[1])
+			if err != nil {
+				t.Fatalf("mode %v: error interpolating with gradient: %v", mode, err)
+			}
+			h := 1e-5
+			nextX, _ := interpolator.Interpolate(q[0]+h, q[1])
+			nextY, _ := interpolator.Interpolate(q[0], q[1]+h)
+			if math.Abs((nextX-value)/h-dx) > 1e-3 || math.Abs((nextY-value)/h-dy) > 1e-3 {
+				t.Errorf("mode %v: expected gradient (%v,%v) at %v but got (%v,%v)", mode, (nextX-value)/h, (nextY-value)/h, q, dx, dy)
+			}
+		}
+		// The clipped weights change continuously across the hull, and match the usual weights away from it.
+		for _, q := range [][2]float64{{0.5, 0}, {1, 0.3}, {0.0001, 0}} {
+			for _, offset := range [][2]float64{{0, 1e-9}, {1e-9, 0}} {
+				inside, err1 := interpolator.Interpolate(q[0]-offset[0], q[1]+offset[1])
+				outside, err2 := interpolator.Interpolate(q[0]+offset[0], q[1]-offset[1])
+				if err1 != nil || err2 != nil || math.Abs(inside-outside) > 1e-6 {
+					t.Errorf("mode %v: expected continuous result across hull at %v but got %v and %v (%v, %v)", mode, q, inside, outside, err1, err2)
+				}
+			}
+		}
+		expected, _ := newInterpolator(Options{Mode: mode, Extrapolation: ExtrapolateError}).Interpolate(0.5, 0.5)
+		if result, err := interpolator.Interpolate(0.5, 0.5); err != nil || math.Abs(result-expected) > Epsilon {
+			t.Errorf("mode %v: expected usual result %v inside hull but got %v (%v)", mode, expected, result, err)
+		}
+	}
+}
+
+func TestInterpolateAtDataPoints(t *testing.T) {
+	for _, mode := range []Mode{Sibson, Laplace, SibsonC1} {
+		rand.Seed(0)
+		points := make([]*delaunay.Point, 200)
+		for i := range points {
+			points[i] = NewPoint(rand.Float64(), rand.Float64(), rand.Float64())
+		}
+		interpolator, err := NewWithOptions(points, Options{Mode: mode})
+		if err != nil {
+			t.Fatalf("error creating interpolator: %v", err)
+		}
+		for _, p := range points {
+			result, err := interpolator.Interpolate(p.X, p.Y)
+			if err != nil {
+				t.Fatalf("error interpolating point: %v", err)
+			}
+			if result != p.Value {
+				t.Errorf("mode %v: expected exact value %v at data point (%v,%v) but got %v", mode, p.Value, p.X, p.Y, result)
+			}
+			value, dx, dy, err := interpolator.InterpolateWithGradient(p.X, p.Y)
+			if err != nil || value != p.Value || math.IsNaN(dx) || math.IsNaN(dy) {
+				t.Errorf("mode %v: expected exact value %v with gradient at data point but got %v, (%v,%v) (%v)", mode, p.Value, value, dx, dy, err)
+			}
+		}
+	}
+	// Duplicates left out of the triangulation are treated as removed.
+	points := []*delaunay.Point{NewPoint(0, 0, 0), NewPoint(1, 0, 1), NewPoint(0, 1, 1), NewPoint(1, 1, 2), NewPoint(1, 1, 4)}
+	if _, err := New(points); !errors.Is(err, delaunay.ErrDuplicatePoint) {
+		t.Errorf("expected error creating interpolator from duplicate points")
+	}
+	interpolator, err := NewWithOptions(points, Options{Duplicates: delaunay.DuplicateAverage})
+	if err != nil {
+		t.Fatalf("error creating interpolator: %v", err)
+	}
+	if result, err := interpolator.Interpolate(1, 1); err != nil || result != 3 {
+		t.Errorf("expected averaged value of 3 but got %v (%v)", result, err)
+	}
+	if err := interpolator.RemovePoint(points[4]); !errors.Is(err, ErrNotDataPoint) {
+		t.Errorf("expected error removing duplicate that was left out")
+	}
+	if err := interpolator.SetValues(nil, []float64{0, 1, 1, 2, 4}); err != nil {
+		t.Errorf("error setting values: %v", err)
+	}
+}
+
+func TestInterpolateAll(t *testing.T) {
+	for _, mode := range []Mode{Sibson, Laplace, SibsonC1} {
+		// Interpolating several values at once should give the same results as interpolating each separately.
+		newInterpolator := func(channel int) *Interpolator {
+			r := rand.New(rand.NewSource(0))
+			points := make([]*delaunay.Point, 500)
+			for i := range points {
+				x, y := r.Float64(), r.Float64()
+				values := []float64{x * y, x - y, r.Float64()}
+				if channel < 0 {
+					points[i] = NewPointWithValues(x, y, values...)
+				} else {
+					points[i] = NewPoint(x, y, values[channel])
+				}
+			}
+			interpolator, err := NewWithOptions(points, Options{Mode: mode})
+			if err != nil {
+				t.Fatalf("error creating interpolator: %v", err)
+			}
+			return interpolator
+		}
+		all := newInterpolator(-1)
+		separate := []*Interpolator{newInterpolator(0), newInterpolator(1), newInterpolator(2)}
+		for i := 0; i < 100; i++ {
+			x, y := 0.1+0.8*rand.Float64(), 0.1+0.8*rand.Float64()
+			results, err := all.InterpolateAll(x, y)
+			if err != nil {
+				t.Fatalf("error interpolating point: %v", err)
+			}
+			for c, s := range separate {
+				expected, err := s.Interpolate(x, y)
+				if err != nil {
+					t.Fatalf("error interpolating point: %v", err)
+				}
+				if math.Abs(results[c]-expected) > Epsilon {
+					t.Errorf("mode %v: expected value %d of %v at (%v,%v) but got %v", mode, c, expected, x, y, results[c])
+				}
+			}
+		}
+	}
+	points := []*delaunay.Point{NewPointWithValues(0, 0, 1, 2), NewPointWithValues(1, 0, 1, 2), NewPoint(0, 1, 1)}
+	if _, err := New(points); !errors.Is(err, delaunay.ErrValueCount) {
+		t.Errorf("expected error creating interpolator from points with different numbers of values")
+	}
+}
+
+func TestSetValues(t *testing.T) {
+	for _, mode := range []Mode{Sibson, SibsonC1} {
+		// Updating values should give the same results as creating an interpolator with the new values.
+		newPoints := func(f func(x, y float64) float64) []*delaunay.Point {
+			r := rand.New(rand.NewSource(0))
+			points := make([]*delaunay.Point, 500)
+			for i := range points {
+				x, y := r.Float64(), r.Float64()
+				points[i] = NewPoint(x, y, f(x, y))
+			}
+			return points
+		}
+		f := func(x, y float64) float64 { return x*x - y }
+		points := newPoints(func(x, y float64) float64 { return 0 })
+		updated, err := NewWithOptions(points, Options{Mode: mode})
+		if err != nil {
+			t.Fatalf("error creating interpolator: %v", err)
+		}
+		expected, err := NewWithOptions(newPoints(f), Options{Mode: mode})
+		if err != nil {
+			t.Fatalf("error creating interpolator: %v", err)
+		}
+		// Update the first half by index and the second half by handle.
+		half := len(points) / 2
+		indices := make([]int, half)
+		values := make([]float64, len(points))
+		for i, p := range points {
+			values[i] = f(p.X, p.Y)
+			if i < half {
+				indices[i] = i
+			}
+		}
+		if err := updated.SetValues(indices, values[:half]); err != nil {
+			t.Fatalf("error setting values: %v", err)
+		}
+		if err := updated.SetPointValues(points[half:], values[half:]); err != nil {
+			t.Fatalf("error setting values: %v", err)
+		}
+		for i := 0; i < 100; i++ {
+			x, y := 0.1+0.8*rand.Float64(), 0.1+0.8*rand.Float64()
+			result, _ := updated.Interpolate(x, y)
+			want, _ := expected.Interpolate(x, y)
+			if math.Abs(result-want) > Epsilon {
+				t.Errorf("mode %v: expected result of %v at (%v,%v) but got %v", mode, want, x, y, result)
+			}
+		}
+		if err := updated.SetValues(nil, values[1:]); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("expected error setting wrong number of values")
+		}
+		if err := updated.SetPointValues([]*delaunay.Point{NewPoint(0, 0, 0)}, []float64{1}); !errors.Is(err, ErrNotDataPoint) {
+			t.Errorf("expected error setting values of unknown point")
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	rand.Seed(0)
+	points := make([]*delaunay.Point, 500)
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), rand.Float64())
+	}
+	interpolator, err := NewWithOptions(points, Options{Extrapolation: ExtrapolateNaN})
+	if err != nil {
+		t.Fatalf("error creating interpolator: %v", err)
+	}
+	xs, ys := make([]float64, 101), make([]float64, 101)
+	for i := range xs {
+		xs[i], ys[i] = 0.1+0.8*rand.Float64(), 0.1+0.8*rand.Float64()
+	}
+	xs[100], ys[100] = 5, 5
+	operator, err := interpolator.Compile(xs, ys)
+	if err != nil {
+		t.Fatalf("error compiling operator: %v", err)
+	}
+	// Applying the operator to new values should match interpolating with those values.
+	values := make([]float64, len(points))
+	for i := range values {
+		values[i] = rand.Float64()
+	}
+	results, err := operator.Apply(values)
+	if err != nil {
+		t.Fatalf("error applying operator: %v", err)
+	}
+	if err := interpolator.SetValues(nil, values); err != nil {
+		t.Fatalf("error setting values: %v", err)
+	}
+	for i := range xs {
+		expected, err := interpolator.Interpolate(xs[i], ys[i])
+		if err != nil {
+			t.Fatalf("error interpolating point: %v", err)
+		}
+		if math.IsNaN(expected) != math.IsNaN(results[i]) || math.Abs(results[i]-expected) > Epsilon {
+			t.Errorf("expected result of %v at (%v,%v) but got %v", expected, xs[i], ys[i], results[i])
+		}
+	}
+	if _, err := operator.Apply(values[1:]); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected error applying operator to wrong number of values")
+	}
+}
+
+func TestRemovePoint(t *testing.T) {
+	for _, mode := range []Mode{Sibson, SibsonC1} {
+		// Removing points should give the same results as creating an interpolator without them.
+		newPoints := func() []*delaunay.Point {
+			r := rand.New(rand.NewSource(0))
+			points := make([]*delaunay.Point, 500)
+			for i := range points {
+				x, y := r.Float64(), r.Float64()
+				points[i] = NewPoint(x, y, x*x-y)
+			}
+			return points
+		}
+		points := newPoints()
+		removed, err := NewWithOptions(points, Options{Mode: mode})
+		if err != nil {
+			t.Fatalf("error creating interpolator: %v", err)
+		}
+		for _, p := range points[:250] {
+			if err := removed.RemovePoint(p); err != nil {
+				t.Fatalf("error removing point: %v", err)
+			}
+		}
+		if points[0] == nil {
+			t.Errorf("expected given slice of points to be left alone")
+		}
+		expected, err := NewWithOptions(newPoints()[250:], Options{Mode: mode})
+		if err != nil {
+			t.Fatalf("error creating interpolator: %v", err)
+		}
+		for i := 0; i < 100; i++ {
+			x, y := 0.2+0.6*rand.Float64(), 0.2+0.6*rand.Float64()
+			result, _ := removed.Interpolate(x, y)
+			want, _ := expected.Interpolate(x, y)
+			if math.Abs(result-want) > Epsilon {
+				t.Errorf("mode %v: expected result of %v at (%v,%v) but got %v", mode, want, x, y, result)
+			}
+		}
+		if err := removed.RemovePoint(points[0]); !errors.Is(err, ErrNotDataPoint) {
+			t.Errorf("expected error removing point twice")
+		}
+	}
+}
+
+func TestMovePoint(t *testing.T) {
+	for _, mode := range []Mode{Sibson, SibsonC1} {
+		rand.Seed(0)
+		points := make([]*delaunay.Point, 500)
+		for i := range points {
+			x, y := rand.Float64(), rand.Float64()
+			points[i] = NewPoint(x, y, x*x-y)
+		}
+		moved, err := NewWithOptions(points, Options{Mode: mode})
+		if err != nil {
+			t.Fatalf("error creating interpolator: %v", err)
+		}
+		// Moving points should give the same results as creating an interpolator with them in their new positions.
+		for i, p := range points {
+			x, y := p.X+0.002*(rand.Float64()-0.5), p.Y+0.002*(rand.Float64()-0.5)
+			if i%10 == 0 {
+				x, y = rand.Float64(), rand.Float64()
+			}
+			if err := moved.MovePoint(p, x, y); err != nil {
+				t.Fatalf("error moving point: %v", err)
+			}
+		}
+		newPoints := make([]*delaunay.Point, len(points))
+		for i, p := range points {
+			newPoints[i] = NewPoint(p.X, p.Y, p.Value)
+		}
+		expected, err := NewWithOptions(newPoints, Options{Mode: mode})
+		if err != nil {
+			t.Fatalf("error creating interpolator: %v", err)
+		}
+		for i := 0; i < 100; i++ {
+			x, y := 0.2+0.6*rand.Float64(), 0.2+0.6*rand.Float64()
+			result, _ := moved.Interpolate(x, y)
+			want, _ := expected.Interpolate(x, y)
+			if math.Abs(result-want) > Epsilon {
+				t.Errorf("mode %v: expected result of %v at (%v,%v) but got %v", mode, want, x, y, result)
+			}
+		}
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	for _, mode := range []Mode{Sibson, Laplace, SibsonC1} {
+		// A cliff down the middle of the points, with the ground at 0 to its left and 10 to its right.
+		rand.Seed(0)
+		points := []*delaunay.Point{NewPoint(0, 0, 0), NewPoint(0, 1, 0), NewPoint(1, 0, 10), NewPoint(1, 1, 10)}
+		for i := 0; i < 300; i++ {
+			x, y := rand.Float64(), rand.Float64()
+			if x < 0.5 {
+				points = append(points, NewPoint(x, y, 0))
+			} else if x > 0.5 {
+				points = append(points, NewPoint(x, y, 10))
+			}
+		}
+		bottom, top := NewPoint(0.5, 0, 5), NewPoint(0.5, 1, 5)
+		points = append(points, bottom, top)
+		interpolator, err := NewWithOptions(points, Options{Mode: mode})
+		if err != nil {
+			t.Fatalf("error creating interpolator: %v", err)
+		}
+		crossing := func(x float64, neighbours []*delaunay.Point) bool {
+			for _, n := range neighbours {
+				if (n.X-0.5)*(x-0.5) < 0 {
+					return true
+				}
+			}
+			return false
+		}
+		blended := false
+		for i := 0; i < 100 && !blended; i++ {
+			x, y := 0.4+0.2*rand.Float64(), rand.Float64()
+			neighbours, _, _ := interpolator.Weights(x, y)
+			blended = crossing(x, neighbours)
+		}
+		if !blended {
+			t.Errorf("mode %v: expected neighbours across the cliff without a constraint", mode)
+		}
+		if err := interpolator.AddConstraint(bottom, top); err != nil {
+			t.Fatalf("error adding constraint: %v", err)
+		}
+		if err := interpolator.AddConstraint(bottom, NewPoint(0.5, 0.5, 0)); !errors.Is(err, ErrNotDataPoint) {
+			t.Errorf("expected error constraining point that is not a data point")
+		}
+		for i := 0; i < 500; i++ {
+			x, y := 0.4+0.2*rand.Float64(), rand.Float64()
+			neighbours, weights, err := interpolator.Weights(x, y)
+			if err != nil {
+				t.Fatalf("error getting weights: %v", err)
+			}
+			if crossing(x, neighbours) {
+				t.Errorf("mode %v: expected no neighbours of (%v,%v) across the cliff", mode, x, y)
+			}
+			total := 0.0
+			for _, w := range weights {
+				total += w
+			}
+			if math.Abs(total-1) > Epsilon {
+				t.Errorf("mode %v: expected weights at (%v,%v) to sum to 1 but got %v", mode, x, y, total)
+			}
+			result, err := interpolator.Interpolate(x, y)
+			if err != nil {
+				t.Fatalf("error interpolating: %v", err)
+			}
+			// The gradients at the points on the cliff are estimated from both sides, so SibsonC1 can overshoot.
+			if mode != SibsonC1 && (x < 0.5 && result > 5+Epsilon || x > 0.5 && result < 5-Epsilon) {
+				t.Errorf("mode %v: expected result at (%v,%v) from its side of the cliff but got %v", mode, x, y, result)
+			}
+		}
+	}
+	// A point still moves when its constraint would cross another at its new location, so the hull and gradients
+	// must follow it.
+	a, b, g, h := NewPoint(5, 2, 1), NewPoint(5, 8, 2), NewPoint(2, 5, 3), NewPoint(1, 1, 4)
+	points := []*delaunay.Point{NewPoint(0, 0, 0), NewPoint(10, 0, 1), NewPoint(10, 10, 2), NewPoint(0, 10, 1), a, b, g, h}
+	interpolator, err := NewWithOptions(points, Options{Mode: SibsonC1})
+	if err != nil {
+		t.Fatalf("error creating interpolator: %v", err)
+	}
+	for _, c := range [][2]*delaunay.Point{{a, b}, {g, h}} {
+		if err := interpolator.AddConstraint(c[0], c[1]); err != nil {
+			t.Fatalf("error adding constraint: %v", err)
+		}
+	}
+	if err := interpolator.MovePoint(g, 20, 9); !errors.Is(err, delaunay.ErrConstraintCrossing) {
+		t.Errorf("expected error moving constraint across another but got %v", err)
+	}
+	if g.X != 20 || g.Y != 9 {
+		t.Fatalf("expected point to be moved")
+	}
+	inHull := false
+	for _, p := range interpolator.hull {
+		inHull = inHull || p == g
+	}
+	if !inHull {
+		t.Errorf("expected moved point to be on the hull")
+	}
+	for _, p := range points {
+		if expected := estimateGradients(p, 1); interpolator.gradients[p][0] != expected[0] {
+			t.Errorf("expected gradient %v at (%v,%v) but got %v", expected[0], p.X, p.Y, interpolator.gradients[p][0])
+		}
+	}
+}
+
+func TestRefine(t *testing.T) {
+	for _, mode := range []Mode{Sibson, Laplace, SibsonC1} {
+		rand.Seed(0)
+		linear := func(x, y float64) float64 { return 2*x + 3*y - 1 }
+		points := []*delaunay.Point{NewPoint(0, 0, linear(0, 0)), NewPoint(1, 0, linear(1, 0)),
+			NewPoint(1, 1, linear(1, 1)), NewPoint(0, 1, linear(0, 1))}
+		for i := 0; i < 100; i++ {
+			x, y := rand.Float64(), rand.Float64()
+			points = append(points, NewPoint(x, y, linear(x, y)))
+		}
+		// ExtrapolateNatural clips the voronoi cells near the hull, where linear functions are then not reproduced.
+		interpolator, err := NewWithOptions(points, Options{Mode: mode, Extrapolation: ExtrapolateError})
+		if err != nil {
+			t.Fatalf("error creating interpolator: %v", err)
+		}
+		added, err := interpolator.Refine(delaunay.RefineOptions{MinAngle: 25, MaxArea: 0.005})
+		if err != nil {
+			t.Fatalf("mode %v: error refining: %v", mode, err)
+		}
+		if len(added) == 0 {
+			t.Errorf("mode %v: expected points to be added", mode)
+		}
+		// Every mode reproduces a linear function, so the points added lie on it.
+		for _, p := range added {
+			if math.Abs(p.Value-linear(p.X, p.Y)) > Epsilon {
+				t.Errorf("mode %v: expected point added at (%v,%v) to have value %v but got %v", mode, p.X, p.Y, linear(p.X, p.Y), p.Value)
+			}
+			neighbours, _, err := interpolator.Weights(p.X, p.Y)
+			if err != nil || len(neighbours) != 1 || neighbours[0] != p {
+				t.Errorf("mode %v: expected point added at (%v,%v) to be a data point", mode, p.X, p.Y)
+			}
+		}
+		for i := 0; i < 100; i++ {
+			x, y := rand.Float64(), rand.Float64()
+			result, err := interpolator.Interpolate(x, y)
+			if err != nil {
+				t.Fatalf("error interpolating: %v", err)
+			}
+			if math.Abs(result-linear(x, y)) > Epsilon {
+				t.Errorf("mode %v: expected result of %v at (%v,%v) but got %v", mode, linear(x, y), x, y, result)
+			}
+		}
+		if err := interpolator.RemovePoint(added[0]); err != nil {
+			t.Errorf("mode %v: error removing point added by refinement: %v", mode, err)
+		}
+	}
+	// Points added in a hole of the domain are given values, so they do not spoil those interpolated around it.
+	r := rand.New(rand.NewSource(0))
+	points := []*delaunay.Point{NewPoint(0, 0, 0), NewPoint(4, 0, 4), NewPoint(4, 4, 8), NewPoint(0, 4, 4)}
+	for i := 0; i < 56; i++ {
+		x, y := 4*r.Float64(), 4*r.Float64()
+		points = append(points, NewPoint(x, y, x+y))
+	}
+	domain := &Domain{Holes: [][]voronoi.Vertex{{{X: 1.5, Y: 1.5}, {X: 2.5, Y: 1.5}, {X: 2.5, Y: 2.5}, {X: 1.5, Y: 2.5}}}}
+	interpolator, err := NewWithOptions(points, Options{Domain: domain})
+	if err != nil {
+		t.Fatalf("error creating interpolator: %v", err)
+	}
+	added, err := interpolator.Refine(delaunay.RefineOptions{MinAngle: 20, MaxArea: 0.5})
+	if err != nil {
+		t.Fatalf("error refining: %v", err)
+	}
+	for _, p := range added {
+		if math.IsNaN(p.Value) {
+			t.Errorf("expected point added at (%v,%v) to have a value", p.X, p.Y)
+		}
+	}
+	for i := 0; i < 2000; i++ {
+		x, y := 4*r.Float64(), 4*r.Float64()
+		if !domain.Contains(x, y) {
+			continue
+		}
+		if result, err := interpolator.Interpolate(x, y); err != nil || math.IsNaN(result) {
+			t.Fatalf("expected a result in the domain at (%v,%v) but got %v (%v)", x, y, result, err)
+		}
+	}
+}
+
+func TestInterpolatorConcurrent(t *testing.T) {
+	rand.Seed(0)
+	points := make([]*delaunay.Point, 1000)
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), rand.Float64())
+	}
+	interpolator, err := New(points)
+	if err != nil {
+		t.Fatalf("error creating interpolator: %v", err)
+	}
+	queries := make([][2]float64, 1000)
+	expected := make([]float64, len(queries))
+	for i := range queries {
+		queries[i] = [2]float64{rand.Float64(), rand.Float64()}
+		if expected[i], err = interpolator.Interpolate(queries[i][0], queries[i][1]); err != nil {
+			t.Fatalf("error interpolating point: %v", err)
+		}
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, q := range queries {
+				result, err := interpolator.Interpolate(q[0], q[1])
+				if err != nil {
+					t.Errorf("error interpolating point: %v", err)
+					return
+				}
+				if result != expected[i] {
+					t.Errorf("expected result of %v at %v but got %v", expected[i], q, result)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDomain(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	points := make([]*delaunay.Point, 200)
+	for i := range points {
+		x, y := r.Float64(), r.Float64()
+		points[i] = NewPoint(x, y, x+y)
+	}
+	// A lake in the middle of a diamond of land.
+	lake := []voronoi.Vertex{{X: 0.4, Y: 0.4}, {X: 0.6, Y: 0.4}, {X: 0.6, Y: 0.6}, {X: 0.4, Y: 0.6}}
+	domain := &Domain{
+		Boundary: []voronoi.Vertex{{X: 0.5, Y: 0}, {X: 1, Y: 0.5}, {X: 0.5, Y: 1}, {X: 0, Y: 0.5}},
+		Holes:    [][]voronoi.Vertex{lake},
+	}
+	// Points can only be in one triangulation at a time.
+	copyPoints := func() []*delaunay.Point {
+		copies := make([]*delaunay.Point, len(points))
+		for i, p := range points {
+			copies[i] = NewPoint(p.X, p.Y, p.Value)
+		}
+		return copies
+	}
+	plain, err := New(copyPoints())
+	if err != nil {
+		t.Fatalf("error creating interpolator: %v", err)
+	}
+	// Clipping the voronoi cells to the hull too would spoil the shares of them estimated below.
+	interpolator, err := NewWithOptions(points, Options{Domain: domain, Extrapolation: ExtrapolateError})
+	if err != nil {
+		t.Fatalf("error creating interpolator: %v", err)
+	}
+	outside := [][2]float64{{0.5, 0.5}, {0.45, 0.55}, {0.1, 0.1}, {0.9, 0.8}}
+	for _, q := range outside {
+		if result, err := interpolator.Interpolate(q[0], q[1]); err != nil || !math.IsNaN(result) {
+			t.Errorf("expected NaN outside domain at %v but got %v (%v)", q, result, err)
+		}
+		if neighbours, _, err := interpolator.Weights(q[0], q[1]); err != nil || neighbours != nil {
+			t.Errorf("expected no neighbours outside domain at %v", q)
+		}
+	}
+	checked := 0
+	for i := 0; i < 200; i++ {
+		x, y := r.Float64(), r.Float64()
+		if !domain.Contains(x, y) {
+			continue
+		}
+		neighbours, weights, err := interpolator.Weights(x, y)
+		if err != nil {
+			t.Fatalf("error getting weights: %v", err)
+		}
+		leaf, err := interpolator.t.Locate(x, y)
+		if err != nil {
+			t.Fatalf("error locating point: %v", err)
+		}
+		minX, minY, maxX, maxY := x, y, x, y
+		for _, n := range getNeighbours(getCavity(leaf, x, y), x, y) {
+			for _, f := range n.facet {
+				minX, maxX = math.Min(minX, f.X), math.Max(maxX, f.X)
+				minY, maxY = math.Min(minY, f.Y), math.Max(maxY, f.Y)
+			}
+		}
+		if !domain.crosses(minX, minY, maxX, maxY) {
+			// Away from the edges of the domain, the weights are unchanged.
+			_, unclipped, _ := plain.Weights(x, y)
+			for j := range weights {
+				if math.Abs(weights[j]-unclipped[j]) > Epsilon {
+					t.Errorf("expected weights at (%v,%v) away from the edges of the domain to be unchanged", x, y)
+				}
+			}
+			continue
+		}
+		// Near them, each weight is the share of the part of the query's voronoi cell inside the domain that would
+		// be taken from that neighbour. Estimate the shares by sampling the cell.
+		shares := map[*delaunay.Point]float64{}
+		total := 0.0
+		const samples = 200
+		for r := 0; r < samples; r++ {
+			for c := 0; c < samples; c++ {
+				sx := minX + (maxX-minX)*(float64(c)+0.5)/samples
+				sy := minY + (maxY-minY)*(float64(r)+0.5)/samples
+				if !domain.Contains(sx, sy) {
+					continue
+				}
+				best := math.Inf(1)
+				var nearest *delaunay.Point
+				for _, p := range points {
+					if d := (sx-p.X)*(sx-p.X) + (sy-p.Y)*(sy-p.Y); d < best {
+						best, nearest = d, p
+					}
+				}
+				if (sx-x)*(sx-x)+(sy-y)*(sy-y) < best {
+					shares[nearest]++
+					total++
+				}
+			}
+		}
+		for j, n := range neighbours {
+			if math.Abs(weights[j]-shares[n]/total) > 0.02 {
+				t.Errorf("expected weight of about %v at (%v,%v) but got %v", shares[n]/total, x, y, weights[j])
+			}
+		}
+		checked++
+	}
+	if checked == 0 {
+		t.Errorf("expected some locations near the edges of the domain")
+	}
+	// What is given outside the domain can be chosen.
+	filled, err := NewWithOptions(copyPoints(), Options{Domain: domain, NoData: NoDataFill, FillValue: -9999, Mode: Laplace})
+	if err != nil {
+		t.Fatalf("error creating interpolator: %v", err)
+	}
+	failing, err := NewWithOptions(copyPoints(), Options{Domain: domain, NoData: NoDataError})
+	if err != nil {
+		t.Fatalf("error creating interpolator: %v", err)
+	}
+	for _, q := range outside {
+		if result, err := filled.Interpolate(q[0], q[1]); err != nil || result != -9999 {
+			t.Errorf("expected fill value outside domain at %v but got %v (%v)", q, result, err)
+		}
+		if _, err := failing.Interpolate(q[0], q[1]); !errors.Is(err, ErrOutsideDomain) {
+			t.Errorf("expected error outside domain at %v but got %v", q, err)
+		}
+	}
+	// A compiled operator gives the same values, inside the domain and out.
+	xs, ys := []float64{0.5, 0.2}, []float64{0.5, 0.8}
+	for _, q := range outside {
+		xs, ys = append(xs, q[0]), append(ys, q[1])
+	}
+	o, err := filled.Compile(xs, ys)
+	if err != nil {
+		t.Fatalf("error compiling operator: %v", err)
+	}
+	values := make([]float64, len(filled.points))
+	for j, p := range filled.points {
+		values[j] = p.Value
+	}
+	results, err := o.Apply(values)
+	if err != nil {
+		t.Fatalf("error applying operator: %v", err)
+	}
+	for j := range xs {
+		if expected, _ := filled.Interpolate(xs[j], ys[j]); math.Abs(results[j]-expected) > Epsilon {
+			t.Errorf("expected operator result of %v at (%v,%v) but got %v", expected, xs[j], ys[j], results[j])
+		}
+	}
+	// A linear surface stays within the values around it, and the weights sum to one.
+	for i := 0; i < 200; i++ {
+		x, y := r.Float64(), r.Float64()
+		if !domain.Contains(x, y) {
+			continue
+		}
+		neighbours, weights, err := filled.Weights(x, y)
+		if err != nil {
+			t.Fatalf("error getting weights: %v", err)
+		}
+		total, min, max := 0.0, math.Inf(1), math.Inf(-1)
+		for j, n := range neighbours {
+			total += weights[j]
+			min, max = math.Min(min, n.Value), math.Max(max, n.Value)
+		}
+		result, _ := filled.Interpolate(x, y)
+		if math.Abs(total-1) > Epsilon || result < min-Epsilon || result > max+Epsilon {
+			t.Errorf("expected weights at (%v,%v) to sum to 1 but got %v", x, y, total)
+		}
+	}
+	if _, err := NewWithOptions(nil, Options{Domain: &Domain{Holes: [][]voronoi.Vertex{lake[:2]}}}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected error for hole with two vertices but got %v", err)
+	}
+}
+
+func TestInterpolateGrid(t *testing.T) {
+	rand.Seed(0)
+	points := make([]*delaunay.Point, 1000)
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), rand.Float64())
+	}
+	interpolator, err := NewWithOptions(points, Options{Workers: 3, Extrapolation: ExtrapolateError})
+	if err != nil {
+		t.Fatalf("error creating interpolator: %v", err)
+	}
+	grid, err := interpolator.InterpolateGrid(0.1, 0.2, 0.9, 0.8, 17, 13)
+	if err != nil {
+		t.Fatalf("error interpolating grid: %v", err)
+	}
+	for r := 0; r < 13; r++ {
+		for c := 0; c < 17; c++ {
+			x, y := 0.1+0.8*float64(c)/16, 0.2+0.6*float64(r)/12
+			expected, err := interpolator.Interpolate(x, y)
+			if err != nil {
+				t.Fatalf("error interpolating point: %v", err)
+			}
+			if math.Abs(grid[r*17+c]-expected) > Epsilon {
+				t.Errorf("expected result of %v at (%v,%v) but got %v", expected, x, y, grid[r*17+c])
+			}
+		}
+	}
+	if _, err := interpolator.InterpolateGrid(-10, -10, 10, 10, 50, 50); err == nil {
+		t.Errorf("expected error interpolating grid outside of bounds")
+	}
+}
+
+func TestWalkLocation(t *testing.T) {
+	rand.Seed(0)
+	points := make([]*delaunay.Point, 1000)
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), rand.Float64())
+	}
+	history, err := New(points)
+	if err != nil {
+		t.Fatalf("error creating interpolator: %v", err)
+	}
+	// Points can only be in one triangulation at a time.
+	copies := make([]*delaunay.Point, len(points))
+	for i, p := range points {
+		copies[i] = NewPoint(p.X, p.Y, p.Value)
+	}
+	walk, err := NewWithOptions(copies, Options{Location: delaunay.LocateWalk})
+	if err != nil {
+		t.Fatalf("error creating interpolator: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		x, y := rand.Float64(), rand.Float64()
+		expected, err1 := history.Interpolate(x, y)
+		result, err2 := walk.Interpolate(x, y)
+		if (err1 == nil) != (err2 == nil) || math.Abs(result-expected) > Epsilon {
+			t.Errorf("expected result of %v (%v) at (%v,%v) but got %v (%v)", expected, err1, x, y, result, err2)
+		}
+	}
+}
+
+func TestMeshInterpolator(t *testing.T) {
+	rand.Seed(0)
+	points := make([]*delaunay.Point, 1000)
+	coords := make([]float64, 0, 2*len(points))
+	values := make([]float64, len(points))
+	for i := range points {
+		points[i] = NewPoint(rand.Float64(), rand.Float64(), rand.Float64())
+		coords = append(coords, points[i].X, points[i].Y)
+		values[i] = points[i].Value
+	}
+	m, err := delaunay.NewMesh(coords)
+	if err != nil {
+		t.Fatalf("error creating mesh: %v", err)
+	}
+	for _, opts := range []Options{
+		{Mode: Sibson, Extrapolation: ExtrapolateNearest},
+		{Mode: Laplace, Extrapolation: ExtrapolateNearest},
+		{Mode: Sibson, Extrapolation: ExtrapolateNatural},
+		{Mode: Laplace, Extrapolation: ExtrapolateNatural},
+	} {
+		copies := make([]*delaunay.Point, len(points))
+		for i, p := range points {
+			copies[i] = NewPoint(p.X, p.Y, p.Value)
+		}
+		interpolator, err := NewWithOptions(copies, opts)
+		if err != nil {
+			t.Fatalf("error creating interpolator: %v", err)
+		}
+		meshInterpolator, err := NewMeshInterpolator(m, values, opts)
+		if err != nil {
+			t.Fatalf("error creating mesh interpolator: %v", err)
+		}
+		xs, ys := make([]float64, 100), make([]float64, 100)
+		for i := range xs {
+			xs[i], ys[i] = 1.2*rand.Float64()-0.1, 1.2*rand.Float64()-0.1
+			expected, err1 := interpolator.Interpolate(xs[i], ys[i])
+			result, err2 := meshInterpolator.Interpolate(xs[i], ys[i])
+			if err1 != nil || err2 != nil || math.Abs(result-expected) > Epsilon {
+				t.Errorf("expected result of %v (%v) at (%v,%v) but got %v (%v)", expected, err1, xs[i], ys[i], result, err2)
+			}
+		}
+		// Weights are given by the indices of the points in the mesh.
+		ids, weights, err := meshInterpolator.Weights(0.5, 0.5)
+		if err != nil {
+			t.Fatalf("error getting weights: %v", err)
+		}
+		value := 0.0
+		for j, id := range ids {
+			value += weights[j] * values[id]
+		}
+		if expected, _ := interpolator.Interpolate(0.5, 0.5); math.Abs(value-expected) > Epsilon {
+			t.Errorf("expected weighted value of %v but got %v", expected, value)
+		}
+		o, err := meshInterpolator.Compile(xs, ys)
+		if err != nil {
+			t.Fatalf("error compiling operator: %v", err)
+		}
+		results, err := o.Apply(values)
+		if err != nil {
+			t.Fatalf("error applying operator: %v", err)
+		}
+		for i := range xs {
+			if expected, _ := meshInterpolator.Interpolate(xs[i], ys[i]); math.Abs(results[i]-expected) > Epsilon {
+				t.Errorf("expected operator result of %v but got %v", expected, results[i])
+			}
+		}
+	}
+	meshInterpolator, err := NewMeshInterpolator(m, values, Options{Extrapolation: ExtrapolateError})
+	if err != nil {
+		t.Fatalf("error creating mesh interpolator: %v", err)
+	}
+	if result, err := meshInterpolator.Interpolate(points[10].X, points[10].Y); err != nil || result != values[10] {
+		t.Errorf("expected exact value of %v at data point but got %v (%v)", values[10], result, err)
+	}
+	if _, err := meshInterpolator.Interpolate(2, 2); !errors.Is(err, ErrOutsideHull) {
+		t.Errorf("expected outside hull error but got %v", err)
+	}
+	if _, err := NewMeshInterpolator(m, values[1:], Options{}); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected invalid argument error but got %v", err)
+	}
+	if _, err := NewMeshInterpolator(m, values, Options{Mode: SibsonC1}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected unsupported error but got %v", err)
+	}
+}
+
 var result float64
 
-func benchmarkInterpolation(n int, b *testing.B) {
+func benchmarkInterpolation(n int, b *testing.B) { benchmarkInterpolationMode(n, Sibson, b) }
+
+func benchmarkInterpolationMode(n int, mode Mode, b *testing.B) {
 	b.StopTimer()
 	rand.Seed(0)
 	dataPoints := make([]*delaunay.Point, n)
@@ -47,7 +1068,7 @@ func benchmarkInterpolation(n int, b *testing.B) {
 	for j := 4; j < n; j++ {
 		dataPoints[j] = NewPoint(rand.Float64(), rand.Float64(), rand.Float64())
 	}
-	interpolator, err := New(dataPoints)
+	interpolator, err := NewWithOptions(dataPoints, Options{Mode: mode})
 	if err != nil {
 		b.Fatalf("error creating interpolator: %v", err)
 	}
@@ -67,3 +1088,28 @@ func BenchmarkInterpolation10000(b *testing.B)   { benchmarkInterpolation(10000,
 func BenchmarkInterpolation50000(b *testing.B)   { benchmarkInterpolation(50000, b) }
 func BenchmarkInterpolation100000(b *testing.B)  { benchmarkInterpolation(100000, b) }
 func BenchmarkInterpolation1000000(b *testing.B) { benchmarkInterpolation(1000000, b) }
+
+func BenchmarkInterpolationLaplace10000(b *testing.B) { benchmarkInterpolationMode(10000, Laplace, b) }
+
+func BenchmarkInterpolateGrid(b *testing.B) {
+	b.StopTimer()
+	rand.Seed(0)
+	dataPoints := make([]*delaunay.Point, 10000)
+	dataPoints[0] = NewPoint(1, 1, 0)
+	dataPoints[1] = NewPoint(1, -1, 0)
+	dataPoints[2] = NewPoint(-1, -1, 0)
+	dataPoints[3] = NewPoint(-1, 1, 0)
+	for j := 4; j < len(dataPoints); j++ {
+		dataPoints[j] = NewPoint(rand.Float64(), rand.Float64(), rand.Float64())
+	}
+	interpolator, err := New(dataPoints)
+	if err != nil {
+		b.Fatalf("error creating interpolator: %v", err)
+	}
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = interpolator.InterpolateGrid(0, 0, 1, 1, 256, 256); err != nil {
+			b.Errorf("error interpolating grid: %v", err)
+		}
+	}
+}
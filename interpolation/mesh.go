@@ -0,0 +1,228 @@
+package interpolation
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/edwardbrowncross/naturalneighbour/delaunay"
+)
+
+// MeshInterpolator provides natural neighbour interpolation within the points of a delaunay.Mesh, with one value
+// per point. It uses much less memory than an Interpolator, so suits very large sets of points, but its points
+// cannot be changed once it is created.
+// Neither the mesh nor the values are modified, so a MeshInterpolator may be used from multiple goroutines at once.
+type MeshInterpolator struct {
+	m      *delaunay.Mesh
+	values []float64
+	opts   Options
+	hull   []int32 // Convex hull of the points of the mesh, anti-clockwise.
+}
+
+// NewMeshInterpolator creates a new MeshInterpolator for the given mesh, where values[i] is the value at the i'th
+// point of the mesh.
+// Options.Mode, Options.Extrapolation, Options.Domain, Options.NoData and Options.FillValue are used as for an
+// Interpolator, and the other options are ignored. SibsonC1 mode is not supported.
+func NewMeshInterpolator(m *delaunay.Mesh, values []float64, opts Options) (*MeshInterpolator, error) {
+	if len(values) != m.NumPoints() {
+		return nil, fmt.Errorf("%w: mesh has %d points but got %d values", ErrInvalidArgument, m.NumPoints(), len(values))
+	}
+	if opts.Mode == SibsonC1 {
+		return nil, fmt.Errorf("%w: cannot interpolate on a mesh in SibsonC1 mode", ErrUnsupported)
+	}
+	if opts.Domain != nil {
+		if err := opts.Domain.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &MeshInterpolator{
+		m:      m,
+		values: append([]float64(nil), values...),
+		opts:   opts,
+		hull:   m.Hull(),
+	}, nil
+}
+
+// Interpolate returns the interpolated value at the given x and y coordinates using natural neighbour interpolation.
+// At a point of the mesh, its value is returned exactly.
+func (i *MeshInterpolator) Interpolate(x, y float64) (float64, error) {
+	w, err := i.getWeighting(-1, x, y)
+	if err != nil {
+		return 0, err
+	}
+	if w == nil {
+		return math.NaN(), nil
+	}
+	if w.noData {
+		return i.opts.FillValue, nil
+	}
+	total := 0.0
+	for j, id := range w.ids {
+		total += i.values[id] * w.weights[j]
+	}
+	return total, nil
+}
+
+// Weights returns the indices in the mesh of the natural neighbours of the given x and y coordinates along with
+// their natural neighbour coordinates, as for Interpolator.Weights.
+func (i *MeshInterpolator) Weights(x, y float64) ([]int, []float64, error) {
+	w, err := i.getWeighting(-1, x, y)
+	if err != nil || w == nil || w.noData {
+		return nil, nil, err
+	}
+	ids := make([]int, len(w.ids))
+	for j, id := range w.ids {
+		ids[j] = int(id)
+	}
+	return ids, w.weights, nil
+}
+
+// Compile precompiles an Operator that interpolates at each of the given locations, as for Interpolator.Compile.
+// The operator's indices are the indices of the points in the mesh.
+func (i *MeshInterpolator) Compile(xs, ys []float64) (*Operator, error) {
+	if len(xs) != len(ys) {
+		return nil, fmt.Errorf("%w: got %d x coordinates but %d y coordinates", ErrInvalidArgument, len(xs), len(ys))
+	}
+	o := &Operator{
+		RowPtr:    make([]int, 1, len(xs)+1),
+		Points:    i.m.NumPoints(),
+		FillValue: i.opts.FillValue,
+	}
+	hint := -1
+	for j := range xs {
+		w, err := i.getWeighting(hint, xs[j], ys[j])
+		if err != nil {
+			return nil, err
+		}
+		if w != nil && w.noData {
+			o.Fill = append(o.Fill, j)
+		} else if w != nil {
+			o.Indices = append(o.Indices, w.ids...)
+			o.Weights = append(o.Weights, w.weights...)
+			if w.triangle >= 0 {
+				hint = w.triangle
+			}
+		}
+		o.RowPtr = append(o.RowPtr, len(o.Indices))
+	}
+	return o, nil
+}
+
+// meshWeighting is the set of points of a mesh, and their weights, used to interpolate at a location.
+type meshWeighting struct {
+	ids      []int32
+	weights  []float64
+	triangle int  // Triangle containing the location, or -1 if it was not found, for use as a search hint.
+	noData   bool // Whether the location is outside the domain, and given Options.FillValue.
+}
+
+// getWeighting finds the points and weights to interpolate with at the given coordinates, starting the search for
+// them from the hint triangle, or from a sample of the points if it is negative.
+// If the coordinates are outside of the convex hull of the points, applies the extrapolation policy, returning nil
+// for ExtrapolateNaN. Outside the domain, applies the no data policy in the same way.
+func (i *MeshInterpolator) getWeighting(hint int, x, y float64) (*meshWeighting, error) {
+	if d := i.opts.Domain; d != nil && !d.Contains(x, y) {
+		switch i.opts.NoData {
+		case NoDataError:
+			return nil, fmt.Errorf("%w: (%f,%f)", ErrOutsideDomain, x, y)
+		case NoDataFill:
+			return &meshWeighting{noData: true, triangle: -1}, nil
+		default:
+			return nil, nil
+		}
+	}
+	var t int
+	var err error
+	if hint < 0 {
+		t, err = i.m.Locate(x, y)
+	} else {
+		t, err = i.m.LocateFrom(hint, x, y)
+	}
+	if err == nil {
+		// At a point of the mesh, its own value is the exact answer, and the natural neighbour weights are undefined.
+		for k := 0; k < 3; k++ {
+			id := i.m.Triangles[3*t+k]
+			if id == delaunay.InfiniteIndex {
+				continue
+			}
+			if px, py := i.m.Point(int(id)); px == x && py == y {
+				return &meshWeighting{ids: []int32{id}, weights: []float64{1}, triangle: t}, nil
+			}
+		}
+		// Ghost triangles cover everything outside the hull, so any other triangle is inside it.
+		if !i.m.IsGhost(t) {
+			neighbours := getNeighbours(getMeshCavity(i.m, t, x, y), x, y)
+			if i.opts.Extrapolation == ExtrapolateNatural && !isCellInHull(neighbours, len(i.hull), i.hullPoint) {
+				if w := i.getClippedWeighting(t, x, y); w != nil {
+					return w, nil
+				}
+			} else if sites, weights, _ := getNaturalCoordinates(neighbours, i.opts.Mode, i.opts.Domain, x, y, false); len(sites) != 0 {
+				w := &meshWeighting{ids: make([]int32, len(sites)), weights: weights, triangle: t}
+				for j, s := range sites {
+					w.ids[j] = s.id
+				}
+				return w, nil
+			}
+			return i.getNearestWeighting(x, y), nil
+		}
+	}
+	switch i.opts.Extrapolation {
+	case ExtrapolateNaN:
+		return nil, nil
+	case ExtrapolateNearest:
+		return i.getNearestWeighting(x, y), nil
+	case ExtrapolateNatural:
+		if err == nil {
+			if w := i.getClippedWeighting(t, x, y); w != nil {
+				return w, nil
+			}
+		}
+		return i.getNearestWeighting(x, y), nil
+	default:
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: (%f,%f)", ErrOutsideHull, x, y)
+	}
+}
+
+// getClippedWeighting returns the natural neighbours of the given coordinates, which lie within the triangle t,
+// and their weights for ExtrapolateNatural, with the voronoi cells clipped to the convex hull of the points of the
+// mesh and the location. Returns nil if no point has a weight.
+func (i *MeshInterpolator) getClippedWeighting(t int, x, y float64) *meshWeighting {
+	if len(i.hull) < 3 {
+		return nil
+	}
+	sites := getMeshCavitySites(i.m, t, x, y)
+	weights := getClippedCoordinates(sites, hullWith(len(i.hull), i.hullPoint, x, y), i.opts.Mode, i.opts.Domain, x, y)
+	if weights == nil {
+		return nil
+	}
+	w := &meshWeighting{triangle: t}
+	for j, s := range sites {
+		if weights[j] > 0 {
+			w.ids = append(w.ids, s.id)
+			w.weights = append(w.weights, weights[j])
+		}
+	}
+	return w
+}
+
+// getNearestWeighting returns a weighting that interpolates linearly between the ends of the edge of the convex
+// hull nearest to the given coordinates.
+func (i *MeshInterpolator) getNearestWeighting(x, y float64) *meshWeighting {
+	j, t, _ := nearestOnHull(len(i.hull), i.hullPoint, x, y)
+	a, b := i.hull[j], i.hull[(j+1)%len(i.hull)]
+	switch t {
+	case 0:
+		return &meshWeighting{ids: []int32{a}, weights: []float64{1}, triangle: -1}
+	case 1:
+		return &meshWeighting{ids: []int32{b}, weights: []float64{1}, triangle: -1}
+	default:
+		return &meshWeighting{ids: []int32{a, b}, weights: []float64{1 - t, t}, triangle: -1}
+	}
+}
+
+// hullPoint returns the coordinates of the j'th point of the convex hull of the points of the mesh.
+func (i *MeshInterpolator) hullPoint(j int) (float64, float64) {
+	return i.m.Point(int(i.hull[j]))
+}
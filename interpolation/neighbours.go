@@ -0,0 +1,289 @@
+package interpolation
+
+import (
+	"github.com/edwardbrowncross/naturalneighbour/delaunay"
+	"github.com/edwardbrowncross/naturalneighbour/geom"
+	"github.com/edwardbrowncross/naturalneighbour/voronoi"
+)
+
+// site is a data point, given either as a point of a Triangulation or by its index in a Mesh.
+type site struct {
+	p    *delaunay.Point // The point, if it is in a Triangulation.
+	id   int32           // Index of the point, if it is in a Mesh.
+	x, y float64
+}
+
+// neighbour is a natural neighbour of a query location.
+type neighbour struct {
+	site
+	area  float64           // Area of the site's voronoi cell that a point inserted at the query location would take.
+	facet [2]voronoi.Vertex // Ends of the voronoi edge that the site would share with a point inserted at the query location.
+	// The points either side of the site around the edge of the cavity. Each end of the facet is the circumcenter of
+	// the site, one of these and the query location.
+	adjacent [2]voronoi.Vertex
+	outside  float64 // Fraction of the site's weight that lies outside the domain, and so is not given to it.
+}
+
+// cavityTriangle is a triangle of the Bowyer-Watson cavity of a query location: one whose circumcircle contains it.
+type cavityTriangle struct {
+	sites    [3]site // Vertices, in clockwise order.
+	cx, cy   float64 // Circumcenter.
+	boundary [3]bool // Whether the edge opposite each vertex is on the boundary of the cavity.
+}
+
+// getNeighbours finds the natural neighbours of the given location from its cavity, and the area of each one's
+// voronoi cell that a point inserted there would take.
+// Rather than inserting a point, it works on the Bowyer-Watson cavity of the location (the triangles whose
+// circumcircles contain it), so it never modifies the triangulation.
+// https://en.wikipedia.org/wiki/Bowyer%E2%80%93Watson_algorithm
+func getNeighbours(triangles []cavityTriangle, x, y float64) []neighbour {
+	neighbours := []neighbour{}
+	for _, t := range triangles {
+		cx, cy := t.cx, t.cy
+		for k, n := range t.sites {
+			// No two data points are at the same coordinates, so they identify the neighbour.
+			idx := 0
+			for idx < len(neighbours) && (neighbours[idx].x != n.x || neighbours[idx].y != n.y) {
+				idx++
+			}
+			if idx == len(neighbours) {
+				neighbours = append(neighbours, neighbour{site: n})
+			}
+			// The area stolen from n is bounded by the voronoi vertices (circumcenters) of the cavity triangles around n
+			// and, where n's edges leave the cavity, by the bisector of n and the query point.
+			// Split it into a piece per triangle, fanning from n to the circumcenter of this triangle and two points on
+			// the lines of n's voronoi edges crossing this triangle's edges at n: the circumcenter the query point would
+			// form with the edge if it is on the cavity boundary, or the edge's midpoint if not.
+			// The pieces have signed area, so they sum to the stolen polygon's area wherever the circumcenters lie.
+			// The triangles are clockwise, so the pieces are too, giving negative determinants.
+			u, w := t.sites[(k+1)%3], t.sites[(k+2)%3]
+			var ux, uy, wx, wy float64
+			if t.boundary[(k+2)%3] {
+				ux, uy = geom.GetCircumcenter(x, y, n.x, n.y, u.x, u.y)
+				neighbours[idx].facet[0] = voronoi.NewVertex(ux, uy)
+				neighbours[idx].adjacent[0] = voronoi.NewVertex(u.x, u.y)
+			} else {
+				ux, uy = (n.x+u.x)/2, (n.y+u.y)/2
+			}
+			if t.boundary[(k+1)%3] {
+				wx, wy = geom.GetCircumcenter(x, y, n.x, n.y, w.x, w.y)
+				neighbours[idx].facet[1] = voronoi.NewVertex(wx, wy)
+				neighbours[idx].adjacent[1] = voronoi.NewVertex(w.x, w.y)
+			} else {
+				wx, wy = (n.x+w.x)/2, (n.y+w.y)/2
+			}
+			neighbours[idx].area += geom.Det3s(n.x, n.y, ux, uy, cx, cy) + geom.Det3s(n.x, n.y, cx, cy, wx, wy)
+		}
+	}
+	// Close off each stolen polygon along the new voronoi edge, running from the last circumcenter back to the first.
+	for i, n := range neighbours {
+		f := n.facet
+		neighbours[i].area += geom.Det3s(n.x, n.y, f[1].X, f[1].Y, f[0].X, f[0].Y)
+		neighbours[i].area /= -2
+	}
+	return neighbours
+}
+
+// getNaturalCoordinates returns the natural neighbours of the given coordinates among the given neighbours, their
+// normalised weights and, optionally, the partial derivatives of those weights.
+// If a domain is given, each weight only counts the part of it inside the domain. The derivatives do not account
+// for this.
+func getNaturalCoordinates(neighbours []neighbour, mode Mode, d *Domain, x, y float64, withGradient bool) ([]site, []float64, []gradient) {
+	if d != nil {
+		clipToDomain(neighbours, d, mode, x, y)
+	}
+	sites := make([]site, 0, len(neighbours))
+	weights := make([]float64, 0, len(neighbours))
+	var grads []gradient
+	if withGradient {
+		grads = make([]gradient, 0, len(neighbours))
+	}
+	var total float64
+	var totalGrad gradient
+	for _, n := range neighbours {
+		var weight float64
+		var g gradient
+		switch mode {
+		case Laplace:
+			weight, g = getLaplaceWeight(n, x, y, withGradient)
+		default:
+			weight, g = getSibsonWeight(n, x, y, withGradient)
+		}
+		weight *= 1 - n.outside
+		g.dx *= 1 - n.outside
+		g.dy *= 1 - n.outside
+		// Points on the circumcircle of the cavity share no voronoi edge with the query point, so are not really
+		// neighbours.
+		if weight <= 0 {
+			continue
+		}
+		sites = append(sites, n.site)
+		weights = append(weights, weight)
+		total += weight
+		if withGradient {
+			grads = append(grads, g)
+			totalGrad.dx += g.dx
+			totalGrad.dy += g.dy
+		}
+	}
+	// Normalise, using the quotient rule for the gradients.
+	for j := range weights {
+		weights[j] /= total
+		if withGradient {
+			grads[j].dx = (grads[j].dx - weights[j]*totalGrad.dx) / total
+			grads[j].dy = (grads[j].dy - weights[j]*totalGrad.dy) / total
+		}
+	}
+	return sites, weights, grads
+}
+
+// getCavity returns the triangles whose circumcircles contain the given location, found by searching outwards from
+// the leaf triangle containing it.
+// If the cavity includes a ghost triangle, which only happens on the hull, the voronoi cell of a point inserted
+// there would be unbounded, so nil is returned.
+// The search does not cross constraints, nor take in triangles that a constraint hides the location from, so that
+// points on the far side of a constraint are not neighbours.
+func getCavity(leaf *delaunay.Triangle, x, y float64) []cavityTriangle {
+	triangles, cavity := getCavityTriangles(leaf, x, y)
+	for _, t := range triangles {
+		if t.IsGhost() {
+			return nil
+		}
+	}
+	result := make([]cavityTriangle, len(triangles))
+	for i, t := range triangles {
+		result[i].cx, result[i].cy = t.GetCircumcenter()
+		for k, p := range t.Points {
+			result[i].sites[k] = site{p: p, x: p.X, y: p.Y}
+			// The edge opposite each vertex is on the boundary of the cavity if the triangle across it is not in the
+			// cavity, or if it is a constraint.
+			result[i].boundary[k] = !cavity[t.GetTriangleOpposite(p)] || isConstrained(t, k)
+		}
+	}
+	return result
+}
+
+// getCavityTriangles returns the triangles whose circumcircles contain the given location, including any ghost
+// triangles, found by searching outwards from the leaf triangle containing it, along with the set of them.
+func getCavityTriangles(leaf *delaunay.Triangle, x, y float64) ([]*delaunay.Triangle, map[*delaunay.Triangle]bool) {
+	cavity := map[*delaunay.Triangle]bool{leaf: true}
+	triangles := []*delaunay.Triangle{leaf}
+	for i := 0; i < len(triangles); i++ {
+		for k, p := range triangles[i].Points {
+			adj := triangles[i].GetTriangleOpposite(p)
+			if adj == nil || cavity[adj] || !adj.CircumcircleContains(x, y) || isConstrained(triangles[i], k) ||
+				isHidden(adj, x, y) {
+				continue
+			}
+			cavity[adj] = true
+			triangles = append(triangles, adj)
+		}
+	}
+	return triangles, cavity
+}
+
+// getCavitySites returns the data points among the vertices of the cavity of the given location, found by
+// searching outwards from the leaf triangle containing it. Unlike getCavity, the cavity may include ghost
+// triangles, so outside the hull these are still all of the natural neighbours of the location.
+func getCavitySites(leaf *delaunay.Triangle, x, y float64) []site {
+	triangles, _ := getCavityTriangles(leaf, x, y)
+	seen := map[*delaunay.Point]bool{}
+	sites := []site{}
+	for _, t := range triangles {
+		for _, p := range t.Points {
+			if !p.IsInfinite() && !seen[p] {
+				seen[p] = true
+				sites = append(sites, site{p: p, x: p.X, y: p.Y})
+			}
+		}
+	}
+	return sites
+}
+
+// isConstrained returns whether the edge of the triangle opposite its k'th vertex is a constraint.
+func isConstrained(t *delaunay.Triangle, k int) bool {
+	return t.Points[(k+1)%3].IsConstrainedTo(t.Points[(k+2)%3])
+}
+
+// isHidden returns whether the given location is strictly outside one of the constrained edges of the triangle, so
+// that the constraint hides the triangle from it.
+func isHidden(t *delaunay.Triangle, x, y float64) bool {
+	for k := 0; k < 3; k++ {
+		a, b := t.Points[k], t.Points[(k+1)%3]
+		// Triangles are clockwise, so locations outside of edge ab are anti-clockwise of it.
+		if a.IsConstrainedTo(b) && geom.Orient2d(a.X, a.Y, b.X, b.Y, x, y) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// getMeshCavity is like getCavity, but for the triangle t of a mesh.
+func getMeshCavity(m *delaunay.Mesh, t int, x, y float64) []cavityTriangle {
+	triangles := getMeshCavityTriangles(m, t, x, y)
+	for _, t := range triangles {
+		if m.IsGhost(t) {
+			return nil
+		}
+	}
+	result := make([]cavityTriangle, len(triangles))
+	for i, t := range triangles {
+		result[i].cx, result[i].cy = m.Circumcenter(t)
+		for k := 0; k < 3; k++ {
+			id := m.Triangles[3*t+k]
+			px, py := m.Point(int(id))
+			result[i].sites[k] = site{id: id, x: px, y: py}
+			// The edge opposite vertex k starts at the next vertex.
+			adj := int(m.Neighbours[3*t+(k+1)%3] / 3)
+			result[i].boundary[k] = !containsInt(triangles, adj)
+		}
+	}
+	return result
+}
+
+// getMeshCavityTriangles is like getCavityTriangles, but for the triangle t of a mesh.
+func getMeshCavityTriangles(m *delaunay.Mesh, t int, x, y float64) []int {
+	triangles := []int{t}
+	for i := 0; i < len(triangles); i++ {
+		for e := 3 * triangles[i]; e < 3*triangles[i]+3; e++ {
+			adj := int(m.Neighbours[e] / 3)
+			if containsInt(triangles, adj) || !m.CircumcircleContains(adj, x, y) {
+				continue
+			}
+			triangles = append(triangles, adj)
+		}
+	}
+	return triangles
+}
+
+// getMeshCavitySites is like getCavitySites, but for the triangle t of a mesh.
+func getMeshCavitySites(m *delaunay.Mesh, t int, x, y float64) []site {
+	sites := []site{}
+	for _, t := range getMeshCavityTriangles(m, t, x, y) {
+		for k := 0; k < 3; k++ {
+			id := m.Triangles[3*t+k]
+			if id == delaunay.InfiniteIndex {
+				continue
+			}
+			seen := false
+			for _, s := range sites {
+				seen = seen || s.id == id
+			}
+			if !seen {
+				px, py := m.Point(int(id))
+				sites = append(sites, site{id: id, x: px, y: py})
+			}
+		}
+	}
+	return sites
+}
+
+// containsInt returns whether the slice contains the value.
+func containsInt(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
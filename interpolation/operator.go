@@ -0,0 +1,98 @@
+package interpolation
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/edwardbrowncross/naturalneighbour/delaunay"
+)
+
+// Operator is a precompiled sparse interpolation operator for a fixed set of query locations, stored in compressed
+// sparse row form. Row j holds the weights that interpolate at the j'th query location.
+// Since natural neighbour weights only depend on the locations of the data points, an Operator can interpolate new
+// values for the same data points in time proportional to the number of weights.
+type Operator struct {
+	RowPtr    []int     // The weights of row j are at positions RowPtr[j] to RowPtr[j+1] of Indices and Weights.
+	Indices   []int32   // Index of each weight's data point, in the slice of points the Interpolator was created with.
+	Weights   []float64 // Weight of each data point.
+	Points    int       // Number of data points the operator expects values for.
+	Fill      []int     // Rows, in increasing order, for locations outside the domain that are given FillValue.
+	FillValue float64   // Value of the rows in Fill, from Options.FillValue.
+}
+
+// Compile precompiles an Operator that interpolates at each of the given locations. Locations outside the convex
+// hull of the data points are treated according to Options.Extrapolation, and locations outside Options.Domain
+// according to Options.NoData, just as when interpolating at them. Rows for locations given NaN are empty, and rows
+// for locations given Options.FillValue are empty and listed in the operator's Fill.
+// SibsonC1 mode does not give a fixed weighting of the data point values, so is not supported.
+func (i *Interpolator) Compile(xs, ys []float64) (*Operator, error) {
+	if len(xs) != len(ys) {
+		return nil, fmt.Errorf("%w: got %d x coordinates but %d y coordinates", ErrInvalidArgument, len(xs), len(ys))
+	}
+	if i.opts.Mode == SibsonC1 {
+		return nil, fmt.Errorf("%w: cannot compile an operator in SibsonC1 mode", ErrUnsupported)
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	o := &Operator{
+		RowPtr:    make([]int, 1, len(xs)+1),
+		Points:    len(i.points),
+		FillValue: i.opts.FillValue,
+	}
+	var hint *delaunay.Triangle
+	for j := range xs {
+		w, err := i.getWeighting(hint, xs[j], ys[j], false)
+		if err != nil {
+			return nil, err
+		}
+		if w != nil && w.noData {
+			o.Fill = append(o.Fill, j)
+		} else if w != nil {
+			for k, p := range w.points {
+				o.Indices = append(o.Indices, int32(i.index[p]))
+				o.Weights = append(o.Weights, w.weights[k])
+			}
+			if w.leaf != nil {
+				hint = w.leaf
+			}
+		}
+		o.RowPtr = append(o.RowPtr, len(o.Indices))
+	}
+	return o, nil
+}
+
+// Apply interpolates the given values of the data points, given in the order of the slice of points the
+// Interpolator was created with, at each of the operator's query locations.
+func (o *Operator) Apply(values []float64) ([]float64, error) {
+	result := make([]float64, len(o.RowPtr)-1)
+	return result, o.ApplyTo(result, values)
+}
+
+// ApplyTo is like Apply, but writes the results into dst, which must have one element per query location.
+func (o *Operator) ApplyTo(dst, values []float64) error {
+	if len(values) != o.Points {
+		return fmt.Errorf("%w: expected %d values but got %d", ErrInvalidArgument, o.Points, len(values))
+	}
+	if len(dst) != len(o.RowPtr)-1 {
+		return fmt.Errorf("%w: expected space for %d results but got %d", ErrInvalidArgument, len(o.RowPtr)-1, len(dst))
+	}
+	fill := o.Fill
+	for j := range dst {
+		if len(fill) != 0 && fill[0] == j {
+			dst[j] = o.FillValue
+			fill = fill[1:]
+			continue
+		}
+		start, end := o.RowPtr[j], o.RowPtr[j+1]
+		if start == end {
+			dst[j] = math.NaN()
+			continue
+		}
+		total := 0.0
+		for k := start; k < end; k++ {
+			total += o.Weights[k] * values[o.Indices[k]]
+		}
+		dst[j] = total
+	}
+	return nil
+}
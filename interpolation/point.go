@@ -1,8 +1,155 @@
 package interpolation
 
-import "github.com/edwardbrowncross/naturalneighbour/delaunay"
+import (
+	"fmt"
+
+	"github.com/edwardbrowncross/naturalneighbour/delaunay"
+)
 
 // NewPoint is an alias for delaunay.NewPoint.
 func NewPoint(x, y, value float64) *delaunay.Point {
 	return delaunay.NewPoint(x, y, value)
 }
+
+// NewPointWithValues is an alias for delaunay.NewPointWithValues.
+func NewPointWithValues(x, y float64, values ...float64) *delaunay.Point {
+	return delaunay.NewPointWithValues(x, y, values...)
+}
+
+// RemovePoint removes a data point from the interpolator, without rebuilding its triangulation.
+// The point's position in the slice of points the Interpolator was created with is left empty, so the positions of
+// the other points do not change.
+func (i *Interpolator) RemovePoint(p *delaunay.Point) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	idx, found := i.index[p]
+	if !found {
+		return fmt.Errorf("%w: (%f,%f)", ErrNotDataPoint, p.X, p.Y)
+	}
+	connected := p.GetConnected()
+	err := i.t.RemovePoint(p)
+	if i.t.HasPoint(p) {
+		return err
+	}
+	// The point can be gone even if there is an error, such as from joining back up a constraint it split.
+	delete(i.index, p)
+	i.points[idx] = nil
+	i.hull = getConvexHull(i.points)
+	if i.gradients != nil {
+		// The points that were connected to p are now connected to different points.
+		delete(i.gradients, p)
+		changed := make(map[*delaunay.Point]bool, len(connected))
+		for _, n := range connected {
+			changed[n] = true
+		}
+		i.updateGradients(changed)
+	}
+	return err
+}
+
+// MovePoint moves a data point of the interpolator to the given coordinates, updating its triangulation rather than
+// rebuilding it. This is much faster than creating a new Interpolator when points move a little at a time.
+// As with delaunay.Triangulation.MovePoint, the point is still moved if one of its constraints would cross another
+// at its new location, but that constraint is removed and an error wrapping delaunay.ErrConstraintCrossing is
+// returned.
+func (i *Interpolator) MovePoint(p *delaunay.Point, x, y float64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, found := i.index[p]; !found {
+		return fmt.Errorf("%w: (%f,%f)", ErrNotDataPoint, p.X, p.Y)
+	}
+	connected := p.GetConnected()
+	// The triangulation can have changed even if there is an error, so the hull and gradients are updated anyway.
+	err := i.t.MovePoint(p, x, y)
+	i.hull = getConvexHull(i.points)
+	if i.gradients != nil {
+		// Gradients are estimated from the positions of connected points, both those p has left and those it has joined.
+		changed := map[*delaunay.Point]bool{p: true}
+		for _, n := range append(connected, p.GetConnected()...) {
+			changed[n] = true
+		}
+		i.updateGradients(changed)
+	}
+	return err
+}
+
+// AddConstraint adds a constraint between two data points to the interpolator's triangulation, as with
+// delaunay.Triangulation.AddConstraint, such as a cliff or river that values should not be blended across.
+// A location's natural neighbours are then only the points it can see without looking across a constraint, so the
+// values on one side of it do not affect those interpolated on the other.
+// In SibsonC1 mode, the gradients at every point are estimated again, so adding many constraints is slow.
+func (i *Interpolator) AddConstraint(p1, p2 *delaunay.Point) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, p := range []*delaunay.Point{p1, p2} {
+		if _, found := i.index[p]; !found {
+			if p == nil {
+				return fmt.Errorf("%w: nil point", ErrNotDataPoint)
+			}
+			return fmt.Errorf("%w: (%f,%f)", ErrNotDataPoint, p.X, p.Y)
+		}
+	}
+	if err := i.t.AddConstraint(p1, p2); err != nil {
+		return err
+	}
+	if i.gradients != nil {
+		// The edges flipped out of the way of the constraint could be anywhere along it.
+		changed := make(map[*delaunay.Point]bool, len(i.index))
+		for p := range i.index {
+			changed[p] = true
+		}
+		i.updateGradients(changed)
+	}
+	return nil
+}
+
+// Refine adds points to the interpolator's triangulation until its triangles meet the bounds of the given options,
+// as with delaunay.Triangulation.Refine, so that it can be used as a quality mesh. Each point added is given the
+// values interpolated at it just before it is added, so the interpolated surface changes little, and is unchanged
+// for values from a linear function, other than near the hull with ExtrapolateNatural. The points added are returned,
+// and become data points following those the interpolator was created with.
+// opts.Values is ignored. Points added outside the domain, such as in its holes, are given the values of the
+// interpolated surface there, as if there were no domain, rather than the no data result. If interpolating at a
+// point gives an error, refinement stops and returns it along with the points added so far.
+// In SibsonC1 mode, the gradients at every point are estimated again once refinement finishes.
+func (i *Interpolator) Refine(opts delaunay.RefineOptions) ([]*delaunay.Point, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	opts.Values = func(x, y float64) ([]float64, error) {
+		// Points added in a hole of the domain, or outside it, are still data points, and a no data result would spoil
+		// the values interpolated around them.
+		w, err := i.getSurfaceWeighting(nil, x, y, false)
+		if err != nil {
+			return nil, err
+		}
+		if i.gradients != nil && w != nil {
+			// Points added earlier are given gradients once they are needed, when they are connected to others.
+			for _, p := range w.points {
+				if _, found := i.gradients[p]; !found {
+					i.gradients[p] = estimateGradients(p, i.channels)
+				}
+			}
+		}
+		values := make([]float64, i.channels)
+		for c := range values {
+			values[c] = i.interpolate(w, x, y, c)
+		}
+		return values, nil
+	}
+	added, err := i.t.Refine(opts)
+	for _, p := range added {
+		i.index[p] = len(i.points)
+		i.points = append(i.points, p)
+	}
+	if len(added) != 0 {
+		i.hull = getConvexHull(i.points)
+	}
+	if i.gradients != nil {
+		changed := make(map[*delaunay.Point]bool, len(i.index))
+		for p := range i.index {
+			changed[p] = true
+		}
+		i.updateGradients(changed)
+	}
+	return added, err
+}
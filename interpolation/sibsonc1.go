@@ -0,0 +1,122 @@
+package interpolation
+
+import (
+	"math"
+
+	"github.com/edwardbrowncross/naturalneighbour/delaunay"
+)
+
+// gradient is an estimate of the partial derivatives of the interpolated surface at a data point.
+type gradient struct {
+	dx, dy float64
+}
+
+// estimateGradients estimates the gradient of the surface of each channel at the given data point by fitting a
+// plane through it that best matches the values of the points it is connected to, weighted by inverse square
+// distance.
+// Gives zero gradients if the connected points do not span a plane.
+func estimateGradients(p *delaunay.Point, channels int) []gradient {
+	// Solve the weighted least squares normal equations for each channel:
+	// [sxx sxy] [dx]   [sxf]
+	// [sxy syy] [dy] = [syf]
+	var sxx, sxy, syy float64
+	sxf := make([]float64, channels)
+	syf := make([]float64, channels)
+	for _, n := range p.GetConnected() {
+		x, y := n.X-p.X, n.Y-p.Y
+		w := 1 / (x*x + y*y)
+		sxx += w * x * x
+		sxy += w * x * y
+		syy += w * y * y
+		for c := range sxf {
+			f := n.GetValue(c) - p.GetValue(c)
+			sxf[c] += w * x * f
+			syf[c] += w * y * f
+		}
+	}
+	grads := make([]gradient, channels)
+	det := sxx*syy - sxy*sxy
+	if det <= 1e-12*sxx*syy {
+		return grads
+	}
+	for c := range grads {
+		grads[c] = gradient{
+			dx: (syy*sxf[c] - sxy*syf[c]) / det,
+			dy: (sxx*syf[c] - sxy*sxf[c]) / det,
+		}
+	}
+	return grads
+}
+
+// interpolateC1 blends the tangent planes of the natural neighbours of the given coordinates using Sibson's C1
+// interpolant, giving a surface with continuous derivatives everywhere except at the data points.
+// The weights should be the Sibson coordinates of the neighbours.
+// https://doc.cgal.org/latest/Interpolation/index.html
+func (i *Interpolator) interpolateC1(points []*delaunay.Point, weights []float64, x, y float64, channel int) float64 {
+	value, _ := i.sibsonC1(points, weights, nil, x, y, channel)
+	return value
+}
+
+// sibsonC1 returns the value of Sibson's C1 interpolant of the given channel at the given coordinates and, if the
+// partial derivatives of the weights are given, its partial derivatives.
+func (i *Interpolator) sibsonC1(points []*delaunay.Point, weights []float64, weightGrads []gradient, x, y float64, channel int) (float64, gradient) {
+	// Sibson's C1 interpolant mixes the linear (C0) interpolant with an average of the neighbours' tangent planes
+	// weighted by weight/distance, in proportions that make the result smooth:
+	// f = (alpha*linear + sqDist*planes/invDist) / (alpha + sqDist), where alpha = dist/invDist.
+	// Each sum is accumulated along with its gradient, for the quotient rule.
+	var invDist, sqDist, dist, linear, planes float64
+	var gInvDist, gSqDist, gDist, gLinear, gPlanes gradient
+	for j, p := range points {
+		dx, dy := x-p.X, y-p.Y
+		d2 := dx*dx + dy*dy
+		value := p.GetValue(channel)
+		g := i.gradients[p][channel]
+		if d2 == 0 {
+			return value, g
+		}
+		d := math.Sqrt(d2)
+		w := weights[j]
+		plane := value + g.dx*dx + g.dy*dy
+		invDist += w / d
+		sqDist += w * d2
+		dist += w * d
+		linear += w * value
+		planes += w / d * plane
+		if weightGrads == nil {
+			continue
+		}
+		wg := weightGrads[j]
+		// Gradients of d, 1/d and d^2 are (dx,dy)/d, -(dx,dy)/d^3 and 2(dx,dy).
+		gInvDist.dx += wg.dx/d - w*dx/(d2*d)
+		gInvDist.dy += wg.dy/d - w*dy/(d2*d)
+		gSqDist.dx += wg.dx*d2 + 2*w*dx
+		gSqDist.dy += wg.dy*d2 + 2*w*dy
+		gDist.dx += wg.dx*d + w*dx/d
+		gDist.dy += wg.dy*d + w*dy/d
+		gLinear.dx += wg.dx * value
+		gLinear.dy += wg.dy * value
+		gPlanes.dx += (wg.dx/d-w*dx/(d2*d))*plane + w/d*g.dx
+		gPlanes.dy += (wg.dy/d-w*dy/(d2*d))*plane + w/d*g.dy
+	}
+	alpha := dist / invDist
+	planesAvg := planes / invDist
+	num := alpha*linear + sqDist*planesAvg
+	den := alpha + sqDist
+	value := num / den
+	if weightGrads == nil {
+		return value, gradient{}
+	}
+	quotient := func(n, gn, d, gd float64) float64 { return (gn*d - n*gd) / (d * d) }
+	// component returns one partial derivative of the value, given the same partial derivative of each sum.
+	component := func(gInvDist, gSqDist, gDist, gLinear, gPlanes float64) float64 {
+		gAlpha := quotient(dist, gDist, invDist, gInvDist)
+		gPlanesAvg := quotient(planes, gPlanes, invDist, gInvDist)
+		gNum := gAlpha*linear + alpha*gLinear + gSqDist*planesAvg + sqDist*gPlanesAvg
+		return quotient(num, gNum, den, gAlpha+gSqDist)
+	}
+	grad := gradient{
+		dx: component(gInvDist.dx, gSqDist.dx, gDist.dx, gLinear.dx, gPlanes.dx),
+		dy: component(gInvDist.dy, gSqDist.dy, gDist.dy, gLinear.dy, gPlanes.dy),
+	}
+	return value, grad
+}
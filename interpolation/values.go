@@ -0,0 +1,83 @@
+package interpolation
+
+import (
+	"fmt"
+
+	"github.com/edwardbrowncross/naturalneighbour/delaunay"
+)
+
+// SetValues replaces the values of the data points at the given indices into the slice of points the Interpolator
+// was created with. If indices is nil, the values of every point are replaced, in order.
+// For points with several values, values holds every value of the first point, then every value of the second,
+// and so on.
+// Only the values change, so this is much faster than creating a new Interpolator. The points themselves are
+// updated, and the update waits for any interpolation in progress to finish.
+// Values for points that have been removed are ignored.
+func (i *Interpolator) SetValues(indices []int, values []float64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	points := i.points
+	if indices != nil {
+		points = make([]*delaunay.Point, len(indices))
+		for j, idx := range indices {
+			if idx < 0 || idx >= len(i.points) {
+				return fmt.Errorf("%w: point index %d out of range [0,%d)", ErrInvalidArgument, idx, len(i.points))
+			}
+			points[j] = i.points[idx]
+		}
+	}
+	return i.setValues(points, values)
+}
+
+// SetPointValues replaces the values of the given data points, which must be points the Interpolator was created
+// with. Values are given in the same way as for SetValues.
+func (i *Interpolator) SetPointValues(points []*delaunay.Point, values []float64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, p := range points {
+		if _, found := i.index[p]; !found {
+			return fmt.Errorf("%w: (%f,%f)", ErrNotDataPoint, p.X, p.Y)
+		}
+	}
+	return i.setValues(points, values)
+}
+
+// setValues replaces the values of the given data points and updates anything derived from them.
+// Nil points are skipped. The caller must hold the write lock.
+func (i *Interpolator) setValues(points []*delaunay.Point, values []float64) error {
+	if len(values) != len(points)*i.channels {
+		return fmt.Errorf("%w: expected %d values for %d points but got %d", ErrInvalidArgument, len(points)*i.channels, len(points), len(values))
+	}
+	for j, p := range points {
+		if p == nil {
+			continue
+		}
+		for c := 0; c < i.channels; c++ {
+			p.SetValue(c, values[j*i.channels+c])
+		}
+	}
+	if i.gradients != nil {
+		// Gradients are estimated from connected points, so those need updating too.
+		changed := map[*delaunay.Point]bool{}
+		for _, p := range points {
+			if p == nil {
+				continue
+			}
+			changed[p] = true
+			for _, n := range p.GetConnected() {
+				changed[n] = true
+			}
+		}
+		i.updateGradients(changed)
+	}
+	return nil
+}
+
+// updateGradients re-estimates the gradients at the given points, ignoring any that are not data points.
+func (i *Interpolator) updateGradients(points map[*delaunay.Point]bool) {
+	for p := range points {
+		if _, found := i.index[p]; found {
+			i.gradients[p] = estimateGradients(p, i.channels)
+		}
+	}
+}
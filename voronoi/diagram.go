@@ -0,0 +1,559 @@
+package voronoi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/edwardbrowncross/naturalneighbour/delaunay"
+	"github.com/edwardbrowncross/naturalneighbour/geom"
+)
+
+// Diagram is the voronoi diagram of every point of a triangulation, with each cell clipped to a polygon, such as for
+// the area each point serves. The cells tile the bounds, sharing the vertices and edges between them.
+type Diagram struct {
+	Bounds   []Vertex // Vertices of the polygon the cells are clipped to, anti-clockwise.
+	Vertices []Vertex // Every vertex of the cells, once each.
+	Edges    []Edge   // Every edge of the cells, once each.
+	Cells    []Cell   // The cell of every point of the triangulation.
+	index    map[*delaunay.Point]int
+}
+
+// Cell is the voronoi cell of a point of a Diagram, clipped to its bounds. Where bounds that are not convex cut
+// across the cell, it is left in several polygons.
+type Cell struct {
+	Site *delaunay.Point // The point in the delaunay triangulation this cell is associated with.
+	// Indices in Diagram.Vertices of the vertices of the cell's polygons, anti-clockwise, one polygon after another.
+	// Empty if the cell is outside the bounds.
+	Verts []int
+	// Indices in Diagram.Edges of the edges of the cell's polygons. The j'th runs from Verts[j] to the next vertex of
+	// the same polygon.
+	Edges []int
+	// Indices in Verts of the first vertex of each of the cell's polygons: just 0 unless the bounds split the cell.
+	Parts []int
+}
+
+// Edge is an edge of a Diagram, between two of its vertices.
+type Edge struct {
+	Verts [2]int // Indices of the ends of the edge in Diagram.Vertices.
+	// Indices in Diagram.Cells of the cells either side of the edge: first the one whose polygon runs from Verts[0]
+	// to Verts[1], then the one whose polygon runs back. The second is -1 along the bounds.
+	Cells [2]int
+}
+
+// NewBox returns the vertices of the rectangle with the given corners, anti-clockwise, for use as the bounds of a
+// Diagram.
+func NewBox(minX, minY, maxX, maxY float64) []Vertex {
+	return []Vertex{{X: minX, Y: minY}, {X: maxX, Y: minY}, {X: maxX, Y: maxY}, {X: minX, Y: maxY}}
+}
+
+// NewDiagram builds the voronoi diagram of every point of the triangulation, with each cell clipped to the given
+// bounds: a simple polygon, which need not be convex, with its vertices in either winding order. Unlike NewRegion,
+// the cells of points on the convex hull are closed off by the bounds, so they have meaningful areas.
+// Each edge of a cell that is not along the bounds is part of the bisector of two points connected by the
+// triangulation, between the circumcenters of the triangles either side of their connection. Where constraints
+// have kept the triangulation from being delaunay, the cells of the points around them overlap.
+// Vertices closer together than a billionth of the size of the bounds are merged.
+// Returns an error wrapping ErrInvalidBounds if the bounds are not a simple polygon, or delaunay.ErrDegenerate if
+// the triangulation has points but no triangles.
+func NewDiagram(t *delaunay.Triangulation, bounds []Vertex) (*Diagram, error) {
+	bounds, err := getBounds(bounds)
+	if err != nil {
+		return nil, err
+	}
+	points := t.GetPoints()
+	if len(points) != 0 && len(points[0].Triangles) == 0 {
+		return nil, fmt.Errorf("%w: triangulation has no three points that are not collinear", delaunay.ErrDegenerate)
+	}
+	d := &Diagram{
+		Bounds: bounds,
+		Cells:  make([]Cell, len(points)),
+		index:  make(map[*delaunay.Point]int, len(points)),
+	}
+	for i, p := range points {
+		d.index[p] = i
+	}
+	minX, minY, maxX, maxY := bounds[0].X, bounds[0].Y, bounds[0].X, bounds[0].Y
+	for _, v := range bounds {
+		minX, maxX = math.Min(minX, v.X), math.Max(maxX, v.X)
+		minY, maxY = math.Min(minY, v.Y), math.Max(maxY, v.Y)
+	}
+	b := diagramBuilder{
+		d:        d,
+		tol:      1e-9 * math.Max(maxX-minX, maxY-minY),
+		vertices: map[[2]int64][]int{},
+		edges:    map[[2]int][]clippedEdge{},
+	}
+	for i, p := range points {
+		d.Cells[i] = b.getCell(i, p)
+	}
+	// A cell, or one of its polygons, can be left with no area where it only reaches a side of the bounds, and the
+	// edges it shares with the cells inside the bounds are then along them.
+	used := make([][2]bool, len(d.Edges))
+	for i, c := range d.Cells {
+		for _, j := range c.Edges {
+			used[j][0] = used[j][0] || d.Edges[j].Cells[0] == i
+			used[j][1] = used[j][1] || d.Edges[j].Cells[1] == i
+		}
+	}
+	for j, e := range d.Edges {
+		if e.Cells[1] < 0 {
+			continue
+		}
+		if !used[j][0] {
+			d.Edges[j] = Edge{Verts: [2]int{e.Verts[1], e.Verts[0]}, Cells: [2]int{e.Cells[1], -1}}
+		} else if !used[j][1] {
+			d.Edges[j].Cells[1] = -1
+		}
+	}
+	return d, nil
+}
+
+// GetCell returns the index in Cells of the cell of the given point, or -1 if it is not a point of the diagram.
+func (d *Diagram) GetCell(p *delaunay.Point) int {
+	if i, found := d.index[p]; found {
+		return i
+	}
+	return -1
+}
+
+// GetPolygons returns the vertices of each of the polygons of the cell with the given index, anti-clockwise.
+func (d *Diagram) GetPolygons(i int) [][]Vertex {
+	c := d.Cells[i]
+	polygons := make([][]Vertex, len(c.Parts))
+	for k, start := range c.Parts {
+		end := len(c.Verts)
+		if k+1 < len(c.Parts) {
+			end = c.Parts[k+1]
+		}
+		for _, v := range c.Verts[start:end] {
+			polygons[k] = append(polygons[k], d.Vertices[v])
+		}
+	}
+	return polygons
+}
+
+// getBounds returns the vertices of the given polygon anti-clockwise, without repeated vertices, or an error if it
+// is not a simple polygon.
+func getBounds(polygon []Vertex) ([]Vertex, error) {
+	bounds := make([]Vertex, 0, len(polygon))
+	for j, v := range polygon {
+		if math.IsNaN(v.X) || math.IsInf(v.X, 0) || math.IsNaN(v.Y) || math.IsInf(v.Y, 0) {
+			return nil, fmt.Errorf("%w: vertex %d is not finite", ErrInvalidBounds, j)
+		}
+		if v != polygon[(j+1)%len(polygon)] {
+			bounds = append(bounds, v)
+		}
+	}
+	if len(bounds) < 3 {
+		return nil, fmt.Errorf("%w: %d distinct vertices", ErrInvalidBounds, len(bounds))
+	}
+	if SignedArea(bounds) == 0 {
+		return nil, fmt.Errorf("%w: polygon has no area", ErrInvalidBounds)
+	}
+	if SignedArea(bounds) < 0 {
+		for j, k := 0, len(bounds)-1; j < k; j, k = j+1, k-1 {
+			bounds[j], bounds[k] = bounds[k], bounds[j]
+		}
+	}
+	// A polygon is simple if its sides only meet where each ends at the start of the next, without doubling back.
+	n := len(bounds)
+	for j, a := range bounds {
+		c, z := bounds[(j+1)%n], bounds[(j+n-1)%n]
+		if geom.Orient2d(z.X, z.Y, a.X, a.Y, c.X, c.Y) == 0 && (z.X-a.X)*(c.X-a.X)+(z.Y-a.Y)*(c.Y-a.Y) > 0 {
+			return nil, fmt.Errorf("%w: side %d doubles back along side %d", ErrInvalidBounds, j, (j+n-1)%n)
+		}
+		for k := j + 2; k < n && k < j+n-1; k++ {
+			if segmentsMeet(a, c, bounds[k], bounds[(k+1)%n]) {
+				return nil, fmt.Errorf("%w: sides %d and %d meet", ErrInvalidBounds, j, k)
+			}
+		}
+	}
+	return bounds, nil
+}
+
+// segmentsMeet returns whether the segment from a to b has any point in common with the segment from c to e.
+func segmentsMeet(a, b, c, e Vertex) bool {
+	o1, o2 := geom.Orient2d(a.X, a.Y, b.X, b.Y, c.X, c.Y), geom.Orient2d(a.X, a.Y, b.X, b.Y, e.X, e.Y)
+	o3, o4 := geom.Orient2d(c.X, c.Y, e.X, e.Y, a.X, a.Y), geom.Orient2d(c.X, c.Y, e.X, e.Y, b.X, b.Y)
+	if (o1 > 0 && o2 < 0 || o1 < 0 && o2 > 0) && (o3 > 0 && o4 < 0 || o3 < 0 && o4 > 0) {
+		return true
+	}
+	return o1 == 0 && isInBox(a, b, c) || o2 == 0 && isInBox(a, b, e) || o3 == 0 && isInBox(c, e, a) ||
+		o4 == 0 && isInBox(c, e, b)
+}
+
+// isInBox returns whether v is in the box with a and b at opposite corners.
+func isInBox(a, b, v Vertex) bool {
+	return math.Min(a.X, b.X) <= v.X && v.X <= math.Max(a.X, b.X) &&
+		math.Min(a.Y, b.Y) <= v.Y && v.Y <= math.Max(a.Y, b.Y)
+}
+
+// isInside returns whether the given coordinates are inside the bounds, and not on them, by the even-odd rule.
+// https://wrf.ecse.rpi.edu/Research/Short_Notes/pnpoly.html
+func isInside(bounds []Vertex, x, y float64) bool {
+	inside := false
+	for k, a := range bounds {
+		c := bounds[(k+1)%len(bounds)]
+		if geom.Orient2d(a.X, a.Y, c.X, c.Y, x, y) == 0 && isInBox(a, c, Vertex{X: x, Y: y}) {
+			return false
+		}
+		if (a.Y > y) != (c.Y > y) && x < a.X+(y-a.Y)*(c.X-a.X)/(c.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// diagramBuilder holds what the cells of a Diagram share while it is built.
+type diagramBuilder struct {
+	d        *Diagram
+	tol      float64                  // Distance within which vertices are merged.
+	vertices map[[2]int64][]int       // Indices of the vertices in each square of a grid with sides of length tol.
+	edges    map[[2]int][]clippedEdge // The parts of the voronoi edge between each pair of cells, smaller index first.
+}
+
+// clippedEdge is a part of a voronoi edge inside the bounds, as seen from one of the cells either side of it, which
+// it runs anti-clockwise around.
+type clippedEdge struct {
+	edge     int      // Index in Diagram.Edges.
+	from, to int      // Indices of its ends in Diagram.Vertices.
+	entry    crossing // Where the edge enters the bounds, if it starts outside them.
+	exit     crossing // Where the edge leaves the bounds, if it ends outside them.
+}
+
+// reversed returns the edge as seen from the cell on its other side.
+func (e clippedEdge) reversed() clippedEdge {
+	return clippedEdge{edge: e.edge, from: e.to, to: e.from, entry: e.exit, exit: e.entry}
+}
+
+// crossing is a point where a voronoi edge crosses the side of the bounds from bounds[side] to the next vertex.
+type crossing struct {
+	side int     // -1 if the edge does not cross the bounds.
+	s    float64 // How far along the side it crosses, from 0 at its start to 1 at its end.
+}
+
+// getCell returns the cell of the point p, which has index i, clipped to the bounds.
+func (b *diagramBuilder) getCell(i int, p *delaunay.Point) Cell {
+	// Walk anti-clockwise around p through its triangles. The voronoi edge dual to each edge from p runs between the
+	// circumcenters of the triangles either side of it.
+	pieces := []clippedEdge{}
+	t0 := p.Triangles[0]
+	for s := t0; ; {
+		k := 0
+		for s.Points[k] != p {
+			k++
+		}
+		// The triangles are clockwise, so the next triangle anti-clockwise is across the edge to the next vertex.
+		u := s.Points[(k+1)%3]
+		next := s.GetAdjacentTo(p, u)
+		if next == nil {
+			break
+		}
+		if !u.IsInfinite() {
+			pieces = append(pieces, b.getEdge(i, p, u, s, next)...)
+		}
+		if s = next; s == t0 {
+			break
+		}
+	}
+	bounds := b.d.Bounds
+	cell := Cell{Site: p}
+	if len(pieces) == 0 {
+		// No edge of the cell reaches inside the bounds, so the cell either holds all of them or none of them. It
+		// holds them only if it holds all of their corners, as the cell is convex.
+		verts, edges := []int{}, []int{}
+		for _, v := range bounds {
+			if !isNearest(p, v.X, v.Y) {
+				return cell
+			}
+			verts = append(verts, b.getVertex(v.X, v.Y))
+			edges = append(edges, -1)
+		}
+		b.addPolygon(&cell, i, verts, edges)
+		return cell
+	}
+	// Each edge leads on to the one that starts where it ends. Where it leaves the bounds instead, run along them,
+	// anti-clockwise like the cell, to where the first edge of the cell enters them again. The bounds are not
+	// convex in general, so this can close off a polygon before every edge has been used, leaving the rest for
+	// others.
+	// Weiler & Atherton, "Hidden surface removal using polygon area sorting" (1977).
+	byFrom, ends := make(map[int]int, len(pieces)), make(map[int]bool, len(pieces))
+	for n, e := range pieces {
+		byFrom[e.from], ends[e.to] = n, true
+	}
+	// Where each edge enters the bounds, or starts on them where rounding has left its end a tiny distance inside.
+	entries := make([]crossing, len(pieces))
+	for n, e := range pieces {
+		entries[n] = crossing{side: -1}
+		if e.entry.side >= 0 || !ends[e.from] {
+			entries[n] = b.getCrossing(e.from, e.entry)
+		}
+	}
+	used := make([]bool, len(pieces))
+	for first := range pieces {
+		// A ring of the polygon's vertices, each with the edge to the next, or -1 to run along the bounds.
+		verts, edges := []int{}, []int{}
+		for n := first; n >= 0 && !used[n]; {
+			used[n] = true
+			e := pieces[n]
+			verts = append(verts, e.from)
+			edges = append(edges, e.edge)
+			if next, found := byFrom[e.to]; found {
+				n = next
+				continue
+			}
+			verts = append(verts, e.to)
+			edges = append(edges, -1)
+			exit := b.getCrossing(e.to, e.exit)
+			if n = getNextEntry(len(bounds), exit, entries); n < 0 {
+				break
+			}
+			for _, k := range getCornersBetween(len(bounds), exit, entries[n]) {
+				verts = append(verts, b.getVertex(bounds[k].X, bounds[k].Y))
+				edges = append(edges, -1)
+			}
+		}
+		b.addPolygon(&cell, i, verts, edges)
+	}
+	return cell
+}
+
+// addPolygon adds a polygon to the cell with index i, given as a ring of vertices, each with the edge to the next or
+// -1 to run along the bounds. It drops vertices that were merged with the one before, and the polygon if that
+// leaves it with no area.
+func (b *diagramBuilder) addPolygon(cell *Cell, i int, verts, edges []int) {
+	polygon, polygonEdges := []int{}, []int{}
+	for j, v := range verts {
+		if prev := len(polygon) - 1; prev >= 0 && polygon[prev] == v {
+			polygonEdges[prev] = edges[j]
+			continue
+		}
+		polygon = append(polygon, v)
+		polygonEdges = append(polygonEdges, edges[j])
+	}
+	for last := len(polygon) - 1; last > 0 && polygon[last] == polygon[0]; last-- {
+		polygon, polygonEdges = polygon[:last], polygonEdges[:last]
+	}
+	if len(polygon) < 3 {
+		return
+	}
+	for j, e := range polygonEdges {
+		if e < 0 {
+			polygonEdges[j] = len(b.d.Edges)
+			b.d.Edges = append(b.d.Edges, Edge{
+				Verts: [2]int{polygon[j], polygon[(j+1)%len(polygon)]},
+				Cells: [2]int{i, -1},
+			})
+		}
+	}
+	cell.Parts = append(cell.Parts, len(cell.Verts))
+	cell.Verts = append(cell.Verts, polygon...)
+	cell.Edges = append(cell.Edges, polygonEdges...)
+}
+
+// getEdge returns the parts inside the bounds of the voronoi edge between the cell of p, which has index i, and
+// that of u, as seen from the cell of p. The edge is dual to the edge from p to u, which has s clockwise of it and
+// next anti-clockwise of it.
+func (b *diagramBuilder) getEdge(i int, p, u *delaunay.Point, s, next *delaunay.Triangle) []clippedEdge {
+	j := b.d.index[u]
+	key := [2]int{i, j}
+	if j < i {
+		key = [2]int{j, i}
+	}
+	if pieces, found := b.edges[key]; found {
+		reversed := make([]clippedEdge, len(pieces))
+		for n, e := range pieces {
+			reversed[len(pieces)-1-n] = e.reversed()
+		}
+		return reversed
+	}
+	pieces := b.clipEdge(i, j, p, u, s, next)
+	b.edges[key] = pieces
+	return pieces
+}
+
+// clipEdge clips the voronoi edge between the cell of p, which has index i, and that of u, which has index j, to
+// the bounds, returning each part of it inside them. The edge is dual to the edge from p to u, which has s
+// clockwise of it and next anti-clockwise of it.
+func (b *diagramBuilder) clipEdge(i, j int, p, u *delaunay.Point, s, next *delaunay.Triangle) []clippedEdge {
+	// The edge is the part of the line (ox,oy) + t(dx,dy) with lo <= t <= hi. Where it is dual to an edge of the
+	// hull, it runs to infinity along the bisector of p and u, away from the hull.
+	var ox, oy, dx, dy, lo, hi float64
+	var endX, endY float64
+	nx, ny := p.Y-u.Y, u.X-p.X
+	switch {
+	case s.IsGhost() && next.IsGhost():
+		return nil
+	case s.IsGhost():
+		ox, oy = next.GetCircumcenter()
+		dx, dy, lo, hi = nx, ny, math.Inf(-1), 0
+		endX, endY = ox, oy
+	case next.IsGhost():
+		ox, oy = s.GetCircumcenter()
+		dx, dy, lo, hi = nx, ny, 0, math.Inf(1)
+	default:
+		ox, oy = s.GetCircumcenter()
+		endX, endY = next.GetCircumcenter()
+		dx, dy, lo, hi = endX-ox, endY-oy, 0, 1
+	}
+	startX, startY := ox, oy
+	// Find where the edge crosses the sides of the bounds, in order along it. Between one crossing and the next, it
+	// is either all inside the bounds or all outside them.
+	bounds := b.d.Bounds
+	n := len(bounds)
+	type cut struct {
+		t float64
+		c crossing
+	}
+	cuts := []cut{{t: lo, c: crossing{side: -1}}}
+	for k, a := range bounds {
+		c := bounds[(k+1)%n]
+		ex, ey := c.X-a.X, c.Y-a.Y
+		den := geom.Det2(dx, dy, ex, ey)
+		if den == 0 {
+			// Parallel to the side. Along it, the side is the edge of the cell instead.
+			continue
+		}
+		wx, wy := a.X-ox, a.Y-oy
+		t, along := geom.Det2(wx, wy, ex, ey)/den, geom.Det2(wx, wy, dx, dy)/den
+		if along < 0 || along > 1 || t <= lo || t >= hi {
+			continue
+		}
+		if along == 1 {
+			k, along = (k+1)%n, 0
+		}
+		cuts = append(cuts, cut{t: t, c: crossing{side: k, s: along}})
+	}
+	cuts = append(cuts, cut{t: hi, c: crossing{side: -1}})
+	between := cuts[1 : len(cuts)-1]
+	sort.Slice(between, func(a, c int) bool { return between[a].t < between[c].t })
+	// isInsideAfter returns whether the edge is inside the bounds between the k'th cut and the next. It is outside them
+	// where it runs to infinity.
+	isInsideAfter := func(k int) bool {
+		t0, t1 := cuts[k].t, cuts[k+1].t
+		if math.IsInf(t0, 0) || math.IsInf(t1, 0) {
+			return false
+		}
+		t := (t0 + t1) / 2
+		return isInside(bounds, ox+t*dx, oy+t*dy)
+	}
+	pieces := []clippedEdge{}
+	for k := 0; k < len(cuts)-1; k++ {
+		if cuts[k+1].t <= cuts[k].t || !isInsideAfter(k) {
+			continue
+		}
+		// Carry on through cuts where the edge only touches the bounds, or passes through a corner of them.
+		first := k
+		for k+2 < len(cuts) && (cuts[k+2].t <= cuts[k+1].t || isInsideAfter(k+1)) {
+			k++
+		}
+		e := clippedEdge{entry: cuts[first].c, exit: cuts[k+1].c}
+		fromX, fromY, toX, toY := startX, startY, endX, endY
+		if e.entry.side >= 0 {
+			fromX, fromY = ox+cuts[first].t*dx, oy+cuts[first].t*dy
+		}
+		if e.exit.side >= 0 {
+			toX, toY = ox+cuts[k+1].t*dx, oy+cuts[k+1].t*dy
+		}
+		e.from, e.to = b.getVertex(fromX, fromY), b.getVertex(toX, toY)
+		if e.from == e.to {
+			continue
+		}
+		e.edge = len(b.d.Edges)
+		b.d.Edges = append(b.d.Edges, Edge{Verts: [2]int{e.from, e.to}, Cells: [2]int{i, j}})
+		pieces = append(pieces, e)
+	}
+	return pieces
+}
+
+// alongSide returns how far along the given side of the bounds the given coordinates are, from 0 at its start to 1
+// at its end.
+func alongSide(bounds []Vertex, side int, x, y float64) float64 {
+	a, c := bounds[side], bounds[(side+1)%len(bounds)]
+	ex, ey := c.X-a.X, c.Y-a.Y
+	return ((x-a.X)*ex + (y-a.Y)*ey) / (ex*ex + ey*ey)
+}
+
+// getCrossing returns the given crossing of the bounds at the vertex with index v or, if the edge was not found to
+// cross them there, where the vertex is on the nearest side of them. Rounding can leave a vertex meant to be on the
+// bounds a tiny distance inside them.
+func (b *diagramBuilder) getCrossing(v int, c crossing) crossing {
+	if c.side >= 0 {
+		return c
+	}
+	bounds, x, y := b.d.Bounds, b.d.Vertices[v].X, b.d.Vertices[v].Y
+	best := math.Inf(1)
+	for k, a := range bounds {
+		s := math.Max(0, math.Min(1, alongSide(bounds, k, x, y)))
+		e := bounds[(k+1)%len(bounds)]
+		if d := math.Hypot(a.X+s*(e.X-a.X)-x, a.Y+s*(e.Y-a.Y)-y); d < best {
+			best, c = d, crossing{side: k, s: s}
+		}
+	}
+	return c
+}
+
+// getCornersBetween returns the indices of the corners of the bounds, which have n corners, passed going
+// anti-clockwise around them from one crossing to another.
+func getCornersBetween(n int, from, to crossing) []int {
+	corners := []int{}
+	if from.side == to.side && to.s >= from.s {
+		return corners
+	}
+	for k := (from.side + 1) % n; ; k = (k + 1) % n {
+		corners = append(corners, k)
+		if k == to.side {
+			return corners
+		}
+	}
+}
+
+// getNextEntry returns the index of the crossing reached first going anti-clockwise around the bounds, which have n
+// corners, from the given one, or -1 if none of them is on the bounds.
+func getNextEntry(n int, from crossing, entries []crossing) int {
+	best, next := math.Inf(1), -1
+	for m, c := range entries {
+		if c.side < 0 {
+			continue
+		}
+		d := float64(c.side) + c.s - float64(from.side) - from.s
+		if d < 0 {
+			d += float64(n)
+		}
+		if d < best {
+			best, next = d, m
+		}
+	}
+	return next
+}
+
+// getVertex returns the index of the vertex of the diagram at the given coordinates, adding it if there is none
+// within the tolerance.
+func (b *diagramBuilder) getVertex(x, y float64) int {
+	gx, gy := int64(math.Floor(x/b.tol)), int64(math.Floor(y/b.tol))
+	for sx := gx - 1; sx <= gx+1; sx++ {
+		for sy := gy - 1; sy <= gy+1; sy++ {
+			for _, v := range b.vertices[[2]int64{sx, sy}] {
+				if math.Hypot(b.d.Vertices[v].X-x, b.d.Vertices[v].Y-y) <= b.tol {
+					return v
+				}
+			}
+		}
+	}
+	v := len(b.d.Vertices)
+	b.d.Vertices = append(b.d.Vertices, NewVertex(x, y))
+	b.vertices[[2]int64{gx, gy}] = append(b.vertices[[2]int64{gx, gy}], v)
+	return v
+}
+
+// isNearest returns whether p is at least as near to the given coordinates as any point it is connected to.
+func isNearest(p *delaunay.Point, x, y float64) bool {
+	d := (x-p.X)*(x-p.X) + (y-p.Y)*(y-p.Y)
+	for _, q := range p.GetConnected() {
+		if (x-q.X)*(x-q.X)+(y-q.Y)*(y-q.Y) < d {
+			return false
+		}
+	}
+	return true
+}
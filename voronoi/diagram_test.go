@@ -0,0 +1,325 @@
+package voronoi
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/edwardbrowncross/naturalneighbour/delaunay"
+	"github.com/edwardbrowncross/naturalneighbour/geom"
+)
+
+func TestDiagram(t *testing.T) {
+	random := func(n int, minX, minY, size float64) func() []*delaunay.Point {
+		return func() []*delaunay.Point {
+			rand.Seed(0)
+			points := make([]*delaunay.Point, n)
+			for i := range points {
+				points[i] = delaunay.NewPoint(minX+size*rand.Float64(), minY+size*rand.Float64(), 0)
+			}
+			return points
+		}
+	}
+	grid := func() []*delaunay.Point {
+		points := make([]*delaunay.Point, 144)
+		for i := range points {
+			points[i] = delaunay.NewPoint(float64(i%12), float64(i/12), 0)
+		}
+		return points
+	}
+	circle := func() []*delaunay.Point {
+		points := make([]*delaunay.Point, 24)
+		for i := range points {
+			a := float64(i) * math.Pi / 12
+			points[i] = delaunay.NewPoint(math.Cos(a), math.Sin(a), 0)
+		}
+		return points
+	}
+	comb := []Vertex{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}}
+	for k := 9; k >= 0; k-- {
+		comb = append(comb, Vertex{X: float64(k) + 0.5, Y: 1}, Vertex{X: float64(k), Y: 10})
+	}
+	for _, c := range []struct {
+		name   string
+		points func() []*delaunay.Point
+		bounds []Vertex
+	}{
+		{"box around the points", random(200, 0, 0, 1), NewBox(-0.5, -0.5, 1.5, 1.5)},
+		{"box through the points", random(200, 0, 0, 1), NewBox(0.2, 0.3, 0.7, 0.6)},
+		{"clockwise triangle", random(200, 0, 0, 1), []Vertex{{X: 0, Y: 0}, {X: 0.5, Y: 2}, {X: 2, Y: 0.3}}},
+		{"far from the origin", random(300, 1e6, 2e6, 1), NewBox(1e6+0.1, 2e6+0.2, 1e6+0.8, 2e6+0.9)},
+		{"box far from the points", random(50, 0, 0, 1), NewBox(100, 100, 101, 101)},
+		{"box within one cell", grid, NewBox(3.2, 3.2, 3.4, 3.3)},
+		{"grid in the bounds", grid, NewBox(-1, -1, 12, 12)},
+		{"grid on the bounds", grid, NewBox(0, 0, 11, 11)},
+		{"grid cells on the bounds", grid, NewBox(0.5, 0.5, 10.5, 10.5)},
+		{"diamond through grid points", grid, []Vertex{{X: 5, Y: 0}, {X: 11, Y: 6}, {X: 6, Y: 11}, {X: 0, Y: 5}}},
+		{"cocircular points", circle, NewBox(-2, -2, 2, 2)},
+		{"L through the grid", grid, []Vertex{
+			{X: 0.5, Y: 0.5}, {X: 10.5, Y: 0.5}, {X: 10.5, Y: 4.3}, {X: 4.3, Y: 4.3}, {X: 4.3, Y: 10.5}, {X: 0.5, Y: 10.5},
+		}},
+		{"L on grid points", grid, []Vertex{
+			{X: 0, Y: 0}, {X: 11, Y: 0}, {X: 11, Y: 4}, {X: 4, Y: 4}, {X: 4, Y: 11}, {X: 0, Y: 11},
+		}},
+		{"comb through the points", random(150, 0, 0, 10), comb},
+		{"comb across few cells", random(5, 0, 0, 10), comb},
+	} {
+		points := c.points()
+		tri, err := delaunay.NewTriangulation(points)
+		if err != nil {
+			t.Fatalf("%s: error creating triangulation: %v", c.name, err)
+		}
+		d, err := NewDiagram(tri, c.bounds)
+		if err != nil {
+			t.Fatalf("%s: error creating diagram: %v", c.name, err)
+		}
+		checkDiagram(t, c.name, d, points)
+	}
+}
+
+func TestDiagramHullCells(t *testing.T) {
+	points := []*delaunay.Point{delaunay.NewPoint(0, 0, 0), delaunay.NewPoint(1, 0, 0), delaunay.NewPoint(0, 1, 0)}
+	tri, err := delaunay.NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	d, err := NewDiagram(tri, NewBox(-10, -10, 10, 10))
+	if err != nil {
+		t.Fatalf("error creating diagram: %v", err)
+	}
+	checkDiagram(t, "three points", d, points)
+	// Every point is on the hull, so the bounds close off every cell.
+	for i, expected := range []float64{10.5 * 10.5, (400 - 10.5*10.5) / 2, (400 - 10.5*10.5) / 2} {
+		c := d.GetCell(points[i])
+		if c < 0 {
+			t.Fatalf("expected point %d to have a cell", i)
+		}
+		polygons := d.GetPolygons(c)
+		if len(polygons) != 1 || math.Abs(PolygonArea(polygons[0])-expected) > 1e-9 {
+			t.Errorf("expected cell of point %d to have area %v but got %v", i, expected, polygons)
+		}
+		along := 0
+		for _, e := range d.Cells[c].Edges {
+			if d.Edges[e].Cells[1] < 0 {
+				along++
+			}
+		}
+		if along == 0 {
+			t.Errorf("expected cell of point %d to have edges along the bounds", i)
+		}
+	}
+	if d.GetCell(delaunay.NewPoint(0, 0, 0)) != -1 {
+		t.Errorf("expected no cell for a point not in the diagram")
+	}
+}
+
+func TestDiagramSplitCell(t *testing.T) {
+	// The cell of the first point reaches up both arms of the U, but not across the gap between them.
+	points := []*delaunay.Point{
+		delaunay.NewPoint(5.5, 8, 0), delaunay.NewPoint(5.5, 1, 0), delaunay.NewPoint(0, 5, 0), delaunay.NewPoint(11, 5, 0),
+	}
+	bounds := []Vertex{
+		{X: 0.2, Y: 0.2}, {X: 10.8, Y: 0.2}, {X: 10.8, Y: 10.8}, {X: 7.1, Y: 10.8},
+		{X: 7.1, Y: 3.3}, {X: 3.9, Y: 3.3}, {X: 3.9, Y: 10.8}, {X: 0.2, Y: 10.8},
+	}
+	tri, err := delaunay.NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	d, err := NewDiagram(tri, bounds)
+	if err != nil {
+		t.Fatalf("error creating diagram: %v", err)
+	}
+	checkDiagram(t, "U", d, points)
+	for i, expected := range []int{2, 1, 1, 1} {
+		if parts := d.Cells[d.GetCell(points[i])].Parts; len(parts) != expected {
+			t.Errorf("expected cell of point %d to have %d polygons but got %d", i, expected, len(parts))
+		}
+	}
+	// Bounds that would be convex but for one reflex corner.
+	points = []*delaunay.Point{delaunay.NewPoint(1, 1, 0), delaunay.NewPoint(3, 1, 0), delaunay.NewPoint(2, 3, 0)}
+	tri, err = delaunay.NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	d, err = NewDiagram(tri, []Vertex{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 4, Y: 4}, {X: 2, Y: 1.5}, {X: 0, Y: 4}})
+	if err != nil {
+		t.Fatalf("error creating diagram: %v", err)
+	}
+	checkDiagram(t, "notched box", d, points)
+}
+
+func TestDiagramDegenerate(t *testing.T) {
+	// Collinear points have no triangles to make the cells from.
+	points := []*delaunay.Point{delaunay.NewPoint(0, 0, 0), delaunay.NewPoint(1, 1, 0), delaunay.NewPoint(2, 2, 0)}
+	tri, err := delaunay.NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	if _, err := NewDiagram(tri, NewBox(-1, -1, 3, 3)); !errors.Is(err, delaunay.ErrDegenerate) {
+		t.Errorf("expected degenerate error for collinear points but got %v", err)
+	}
+	// Adding a point that is not collinear gives them cells.
+	points = append(points, delaunay.NewPoint(2, 0, 0))
+	if _, err := tri.AddPoint(points[3]); err != nil {
+		t.Fatalf("error adding point: %v", err)
+	}
+	d, err := NewDiagram(tri, NewBox(-1, -1, 3, 3))
+	if err != nil {
+		t.Fatalf("error creating diagram: %v", err)
+	}
+	checkDiagram(t, "collinear and one more", d, points)
+	// Duplicate sites share the cell of the one the triangulation keeps.
+	rand.Seed(0)
+	points = make([]*delaunay.Point, 50)
+	for i := range points {
+		points[i] = delaunay.NewPoint(rand.Float64(), rand.Float64(), 0)
+	}
+	for i := 0; i < 5; i++ {
+		points = append(points, delaunay.NewPoint(points[i].X, points[i].Y, 0))
+	}
+	tri, err = delaunay.NewTriangulationWithOptions(points, delaunay.Options{Duplicates: delaunay.DuplicateKeepFirst})
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	d, err = NewDiagram(tri, NewBox(0, 0, 1, 1))
+	if err != nil {
+		t.Fatalf("error creating diagram: %v", err)
+	}
+	checkDiagram(t, "duplicate sites", d, points[:50])
+	if len(d.Cells) != 50 {
+		t.Errorf("expected 50 cells but got %d", len(d.Cells))
+	}
+	for _, p := range points[50:] {
+		if d.GetCell(p) != -1 {
+			t.Errorf("expected no cell for a duplicate point")
+		}
+	}
+}
+
+func TestDiagramInvalidBounds(t *testing.T) {
+	points := []*delaunay.Point{delaunay.NewPoint(0, 0, 0), delaunay.NewPoint(1, 0, 0), delaunay.NewPoint(0, 1, 0)}
+	tri, err := delaunay.NewTriangulation(points)
+	if err != nil {
+		t.Fatalf("error creating triangulation: %v", err)
+	}
+	for _, c := range []struct {
+		name   string
+		bounds []Vertex
+	}{
+		{"no vertices", nil},
+		{"two distinct vertices", []Vertex{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 1, Y: 1}}},
+		{"vertex that is not finite", []Vertex{{X: 0, Y: 0}, {X: 1, Y: math.NaN()}, {X: 0, Y: 1}}},
+		{"collinear vertices", []Vertex{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 3, Y: 3}}},
+		{"crossing sides", []Vertex{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 1, Y: 0}, {X: 0, Y: 1}}},
+		{"side doubling back", []Vertex{{X: 0, Y: 0}, {X: 2, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}},
+		{"vertex touching a side", []Vertex{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 4, Y: 4}, {X: 2, Y: 0}, {X: 0, Y: 4}}},
+		{"repeated vertex", []Vertex{
+			{X: 0, Y: 0}, {X: 2, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 2}, {X: 0, Y: 2}, {X: 1, Y: 1},
+		}},
+	} {
+		if _, err := NewDiagram(tri, c.bounds); !errors.Is(err, ErrInvalidBounds) {
+			t.Errorf("%s: expected invalid bounds error but got %v", c.name, err)
+		}
+	}
+}
+
+// checkDiagram checks that the cells of a diagram of the given points tile its bounds, sharing their vertices and
+// edges, and that each cell is the part of the bounds nearest to its point.
+func checkDiagram(t *testing.T, name string, d *Diagram, points []*delaunay.Point) {
+	t.Helper()
+	bounds := d.Bounds
+	minX, minY, maxX, maxY := bounds[0].X, bounds[0].Y, bounds[0].X, bounds[0].Y
+	for _, v := range bounds {
+		minX, maxX = math.Min(minX, v.X), math.Max(maxX, v.X)
+		minY, maxY = math.Min(minY, v.Y), math.Max(maxY, v.Y)
+	}
+	size := math.Max(maxX-minX, maxY-minY)
+	if len(d.Cells) != len(points) {
+		t.Errorf("%s: expected %d cells but got %d", name, len(points), len(d.Cells))
+	}
+	total := 0.0
+	uses := make([]int, len(d.Edges))
+	used := make([]bool, len(d.Vertices))
+	for i, c := range d.Cells {
+		if len(c.Edges) != len(c.Verts) {
+			t.Errorf("%s: expected cell %d to have as many edges as vertices", name, i)
+			continue
+		}
+		for k, polygon := range d.GetPolygons(i) {
+			if area := SignedArea(polygon); area <= 0 {
+				t.Errorf("%s: expected polygon %d of cell %d to be anti-clockwise but it has area %v", name, k, i, area)
+			}
+			total += PolygonArea(polygon)
+			for _, v := range polygon {
+				dist := math.Hypot(v.X-c.Site.X, v.Y-c.Site.Y)
+				for _, q := range points {
+					if math.Hypot(v.X-q.X, v.Y-q.Y) < dist-1e-9*size {
+						t.Errorf("%s: expected (%v,%v) in cell %d to be nearest its point", name, v.X, v.Y, i)
+						break
+					}
+				}
+			}
+		}
+		for k, start := range c.Parts {
+			end := len(c.Verts)
+			if k+1 < len(c.Parts) {
+				end = c.Parts[k+1]
+			}
+			for j := start; j < end; j++ {
+				from, to := c.Verts[j], c.Verts[start+(j+1-start)%(end-start)]
+				used[from] = true
+				e := d.Edges[c.Edges[j]]
+				if !(e.Cells[0] == i && e.Verts == [2]int{from, to}) && !(e.Cells[1] == i && e.Verts == [2]int{to, from}) {
+					t.Errorf("%s: expected edge %d of cell %d to run between %d and %d but got %v", name, j, i, from, to, e)
+				}
+				uses[c.Edges[j]]++
+			}
+		}
+	}
+	for j, e := range d.Edges {
+		expected := 2
+		if e.Cells[1] < 0 {
+			// Edges of a single cell close it off along the bounds.
+			expected = 1
+			if !isOnBounds(bounds, d.Vertices[e.Verts[0]], d.Vertices[e.Verts[1]], 1e-9*size) {
+				t.Errorf("%s: expected edge %d along the bounds to be on them", name, j)
+			}
+		}
+		if uses[j] != expected {
+			t.Errorf("%s: expected edge %d to be in %d cells but it is in %d", name, j, expected, uses[j])
+		}
+	}
+	// Vertices the cells share are only listed once.
+	for j, v := range d.Vertices {
+		if !used[j] {
+			t.Errorf("%s: expected vertex %d to be in a cell", name, j)
+		}
+		for _, u := range d.Vertices[j+1:] {
+			if math.Hypot(u.X-v.X, u.Y-v.Y) <= 1e-9*size {
+				t.Errorf("%s: expected (%v,%v) to be listed once", name, v.X, v.Y)
+			}
+		}
+	}
+	if area := SignedArea(bounds); math.Abs(total-area) > 1e-9*area {
+		t.Errorf("%s: expected cells to have total area %v but got %v", name, area, total)
+	}
+}
+
+// isOnBounds returns whether the segment from a to b is along one side of the bounds, to within tol.
+func isOnBounds(bounds []Vertex, a, b Vertex, tol float64) bool {
+	for k, v := range bounds {
+		w := bounds[(k+1)%len(bounds)]
+		length := math.Hypot(w.X-v.X, w.Y-v.Y)
+		on := func(p Vertex) bool {
+			s := ((p.X-v.X)*(w.X-v.X) + (p.Y-v.Y)*(w.Y-v.Y)) / (length * length)
+			return s >= -tol/length && s <= 1+tol/length &&
+				math.Abs(geom.Det2(w.X-v.X, w.Y-v.Y, p.X-v.X, p.Y-v.Y))/length <= tol
+		}
+		if on(a) && on(b) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,10 @@
+package voronoi
+
+import "errors"
+
+// Errors returned when building voronoi diagrams. They are wrapped with details of the failure, so should be checked
+// for with errors.Is.
+var (
+	// ErrInvalidBounds is returned for bounds that are not a simple polygon.
+	ErrInvalidBounds = errors.New("bounds are not a simple polygon")
+)
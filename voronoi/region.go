@@ -1,6 +1,8 @@
 package voronoi
 
 import (
+	"math"
+
 	"github.com/edwardbrowncross/naturalneighbour/delaunay"
 	"github.com/edwardbrowncross/naturalneighbour/geom"
 )
@@ -12,6 +14,10 @@ type Region struct {
 }
 
 // NewRegion creates a new veronoi region for the given delaunay point.
+// The cell of a point on the convex hull is unbounded. Its polygon only has the vertices of the cell's finite edges,
+// so its area is not meaningful. NewDiagram gives cells that are closed off by bounds.
+// The cell does not reach across constraints of the triangulation: wherever a constraint hides part of it from p,
+// it is clipped to the side of the constraint that p is on.
 func NewRegion(p *delaunay.Point) Region {
 	verts := []Vertex{}
 	// Vertices are the circumcenters of the delaunay triangles surrounding the point.
@@ -24,8 +30,10 @@ func NewRegion(p *delaunay.Point) Region {
 		curp = t0.Points[1]
 	}
 	for true {
-		v := NewVertex(curt.GetCircumcenter())
-		verts = append(verts, v)
+		// Ghost triangles have no circumcenter, as their voronoi vertex is at infinity.
+		if !curt.IsGhost() {
+			verts = append(verts, NewVertex(curt.GetCircumcenter()))
+		}
 		newt := curt.GetAdjacentTo(p, curp)
 		if newt == t0 || newt == nil {
 			break
@@ -39,6 +47,17 @@ func NewRegion(p *delaunay.Point) Region {
 		}
 		curt = newt
 	}
+	// Clipping removes the hidden vertex, and any new vertices are on the constraint, so this finishes.
+	for hidden := len(verts) == len(p.Triangles); hidden; {
+		hidden = false
+		for _, v := range verts {
+			if a, b := getConstraintHiding(p, v.X, v.Y); a != nil {
+				verts = clip(verts, a, b, p)
+				hidden = true
+				break
+			}
+		}
+	}
 	r := Region{
 		Center: p,
 		Verts:  verts,
@@ -46,6 +65,109 @@ func NewRegion(p *delaunay.Point) Region {
 	return r
 }
 
+// getConstraintHiding returns the ends of the first constraint that the segment from p to the given coordinates
+// crosses, or nils if it crosses none, by walking across the triangles along it.
+func getConstraintHiding(p *delaunay.Point, x, y float64) (*delaunay.Point, *delaunay.Point) {
+	o := p
+	for {
+		// Find the triangle around o that the segment leaves o through, with l to its left and r to its right, or the
+		// point of the triangulation it passes through next.
+		var s *delaunay.Triangle
+		var l, r, through *delaunay.Point
+		for _, t := range o.Triangles {
+			if t.IsGhost() {
+				continue
+			}
+			k := 0
+			for t.Points[k] != o {
+				k++
+			}
+			u, w := t.Points[(k+1)%3], t.Points[(k+2)%3]
+			if ou := geom.Orient2d(o.X, o.Y, u.X, u.Y, x, y); ou == 0 && (u.X-o.X)*(x-o.X)+(u.Y-o.Y)*(y-o.Y) > 0 {
+				through = u
+				break
+			} else if ou < 0 && geom.Orient2d(o.X, o.Y, w.X, w.Y, x, y) > 0 {
+				s, l, r = t, u, w
+				break
+			}
+		}
+		for through == nil {
+			// The segment heads out of the hull, or ends before crossing the far edge of s.
+			if s == nil || geom.Orient2d(l.X, l.Y, r.X, r.Y, x, y) <= 0 {
+				return nil, nil
+			}
+			if l.IsConstrainedTo(r) {
+				return l, r
+			}
+			next := s.GetAdjacentTo(l, r)
+			if next == nil || next.IsGhost() {
+				return nil, nil
+			}
+			v := next.GetPointOpposite(s)
+			switch side := geom.Orient2d(o.X, o.Y, x, y, v.X, v.Y); {
+			case side > 0:
+				l = v
+			case side < 0:
+				r = v
+			default:
+				through = v
+			}
+			s = next
+		}
+		// The segment ends before it reaches the point it would pass through.
+		if (x-through.X)*(through.X-o.X)+(y-through.Y)*(through.Y-o.Y) <= 0 {
+			return nil, nil
+		}
+		o = through
+	}
+}
+
+// clip returns the part of the polygon with the given vertices on the same side of the line through a and b as p.
+// Sutherland & Hodgman, "Reentrant polygon clipping" (1974).
+func clip(verts []Vertex, a, b, p *delaunay.Point) []Vertex {
+	side := func(v Vertex) float64 {
+		o := geom.Orient2d(a.X, a.Y, b.X, b.Y, v.X, v.Y)
+		if geom.Orient2d(a.X, a.Y, b.X, b.Y, p.X, p.Y) < 0 {
+			return -o
+		}
+		return o
+	}
+	clipped := make([]Vertex, 0, len(verts)+1)
+	for i, v1 := range verts {
+		v2 := verts[(i+1)%len(verts)]
+		s1, s2 := side(v1), side(v2)
+		if s1 >= 0 {
+			clipped = append(clipped, v1)
+		}
+		if s1 > 0 && s2 < 0 || s1 < 0 && s2 > 0 {
+			// Where the edge crosses the line, found from the distances of its ends from the line.
+			d1 := geom.Det3s(a.X, a.Y, b.X, b.Y, v1.X, v1.Y)
+			d2 := geom.Det3s(a.X, a.Y, b.X, b.Y, v2.X, v2.Y)
+			f := d1 / (d1 - d2)
+			clipped = append(clipped, NewVertex(v1.X+f*(v2.X-v1.X), v1.Y+f*(v2.Y-v1.Y)))
+		}
+	}
+	return clipped
+}
+
+// NewMeshRegion creates a new voronoi region for the point of the mesh with the given index.
+// Its Center is a new point at the coordinates of the mesh point, which is not part of any triangulation.
+// As with NewRegion, the cell of a point on the convex hull is unbounded, and only has the vertices of its finite
+// edges.
+func NewMeshRegion(m *delaunay.Mesh, i int) Region {
+	verts := []Vertex{}
+	for _, t := range m.Star(i) {
+		if !m.IsGhost(int(t)) {
+			verts = append(verts, NewVertex(m.Circumcenter(int(t))))
+		}
+	}
+	x, y := m.Point(i)
+	return Region{
+		Center: delaunay.NewPoint(x, y, 0),
+		Verts:  verts,
+	}
+}
+
 // GetArea returns the area of a voronoi cell.
 func (r Region) GetArea() float64 {
 	lv := len(r.Verts)
@@ -57,3 +179,23 @@ func (r Region) GetArea() float64 {
 	}
 	return sum
 }
+
+// PolygonArea returns the area of the polygon with the given vertices, which may be in either winding order.
+func PolygonArea(verts []Vertex) float64 {
+	return math.Abs(SignedArea(verts))
+}
+
+// SignedArea returns the area of the polygon with the given vertices, positive if they are anti-clockwise.
+func SignedArea(verts []Vertex) float64 {
+	if len(verts) == 0 {
+		return 0
+	}
+	// Working relative to the first vertex keeps the precision of coordinates far from the origin.
+	o := verts[0]
+	sum := 0.0
+	for i, v1 := range verts {
+		v2 := verts[(i+1)%len(verts)]
+		sum += geom.Det2(v1.X-o.X, v1.Y-o.Y, v2.X-o.X, v2.Y-o.Y)
+	}
+	return sum / 2
+}